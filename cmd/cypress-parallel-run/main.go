@@ -0,0 +1,203 @@
+// Command cypress-parallel-run launch unit testing of a project, wait for the run to be over
+// and exit with the code suggested by the api so CI pipelines fail when specs fail.
+//
+//	cypress-parallel-run -project kitchensink -branch main -commit "$CI_COMMIT_SHA"
+//
+// Exit codes are 0 when all specs passed, 1 when one of them failed or the run could not be launched,
+// 2 when the run was cancelled, 3 when the run was not over before timeout and 4 when the api could not be reached
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Lord-Y/cypress-parallel-api/commons"
+)
+
+// maxWait is the longest time, in seconds, the api accepts to wait for a run in a single request
+const maxWait = 600
+
+// exitError is the exit code used when the api could not be reached or returned an error
+const exitError = 4
+
+// verdict hold what the api returns once done waiting for a run
+type verdict struct {
+	Run      map[string]string   `json:"run"`
+	Done     bool                `json:"done"`
+	ExitCode int                 `json:"exitCode"`
+	Summary  map[string]int      `json:"summary"`
+	Specs    []map[string]string `json:"specs"`
+}
+
+// client hold requirements to talk to the api
+type client struct {
+	apiURL string
+	http   *http.Client
+}
+
+// errorOf return the error of an api response which is not the one expected
+func errorOf(resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	b, _ := io.ReadAll(resp.Body)
+	if json.Unmarshal(b, &body) == nil && body.Error != "" {
+		return fmt.Errorf("Api answered %d: %s", resp.StatusCode, body.Error)
+	}
+	return fmt.Errorf("Api answered %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+}
+
+// launch start the plain launch of the form provided and return the uniq id of the run
+func (c *client) launch(form url.Values) (uniqID string, err error) {
+	resp, err := c.http.PostForm(c.apiURL+"/api/v1/cypress-parallel-api/hooks/launch/plain", form)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return uniqID, errorOf(resp)
+	}
+
+	var accepted struct {
+		UniqID string `json:"uniqId"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&accepted)
+	return accepted.UniqID, err
+}
+
+// wait block until the run of the uniq id provided is over or timeout is reached
+func (c *client) wait(uniqID string, timeout time.Duration) (v verdict, err error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		seconds := int(time.Until(deadline) / time.Second)
+		if seconds < 0 {
+			seconds = 0
+		}
+		if seconds > maxWait {
+			seconds = maxWait
+		}
+
+		resp, err := c.http.Get(fmt.Sprintf("%s/api/v1/cypress-parallel-api/runs/%s/wait?timeout=%d", c.apiURL, url.PathEscape(uniqID), seconds))
+		if err != nil {
+			return v, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = errorOf(resp)
+			resp.Body.Close()
+			return v, err
+		}
+		v = verdict{}
+		err = json.NewDecoder(resp.Body).Decode(&v)
+		resp.Body.Close()
+		if err != nil || v.Done || !time.Now().Before(deadline) {
+			return v, err
+		}
+	}
+}
+
+// report write the status of every spec of the run and its summary
+func report(w io.Writer, uniqID string, v verdict) {
+	for _, spec := range v.Specs {
+		line := fmt.Sprintf("%-11s %s", spec["execution_status"], spec["spec"])
+		if duration, err := strconv.ParseFloat(spec["duration"], 64); err == nil {
+			line += fmt.Sprintf(" (%.1fs)", duration)
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	var statuses []string
+	for status, count := range v.Summary {
+		if status != "total" {
+			statuses = append(statuses, fmt.Sprintf("%d %s", count, status))
+		}
+	}
+	sort.Strings(statuses)
+	fmt.Fprintf(w, "Run %s is %s, %d specs", uniqID, v.Run["status"], v.Summary["total"])
+	if len(statuses) > 0 {
+		fmt.Fprintf(w, ": %s", strings.Join(statuses, ", "))
+	}
+	fmt.Fprintln(w)
+	if v.Run["reason"] != "" {
+		fmt.Fprintf(w, "Reason: %s\n", v.Run["reason"])
+	}
+}
+
+// run launch and wait for the run described by args and return the exit code
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("cypress-parallel-run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	apiURL := flags.String("api-url", commons.GetAPIUrl(), "url of cypress-parallel-api, CYPRESS_PARALLEL_API_URL by default")
+	project := flags.String("project", "", "name of the project to launch")
+	branch := flags.String("branch", "", "branch to test, the project one by default")
+	commit := flags.String("commit", "", "commit SHA or tag to test instead of the head of the branch")
+	specs := flags.String("specs", "", "specs file or directory to run, the project ones by default")
+	browser := flags.String("browser", "", "browser to use, chrome or firefox")
+	configFile := flags.String("config-file", "", "cypress config file")
+	cypressDockerVersion := flags.String("cypress-docker-version", "", "cypress docker version")
+	maxPods := flags.Int("max-pods", 0, "maximum number of pods running at the same time")
+	triggeredBy := flags.String("triggered-by", "cli", "who or what launched the run")
+	uniqID := flags.String("uniq-id", "", "wait for the run of this uniq id instead of launching a new one")
+	timeout := flags.Duration("timeout", time.Hour, "how long to wait for the run to be over")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if (*project == "") == (*uniqID == "") {
+		fmt.Fprintln(stderr, "One of -project or -uniq-id is required")
+		flags.Usage()
+		return exitError
+	}
+
+	c := client{
+		apiURL: strings.TrimSuffix(*apiURL, "/"),
+		http:   &http.Client{Timeout: (maxWait + 30) * time.Second},
+	}
+	if *project != "" {
+		form := url.Values{}
+		for key, value := range map[string]string{
+			"project_name":           *project,
+			"branch":                 *branch,
+			"commit":                 *commit,
+			"specs":                  *specs,
+			"browser":                *browser,
+			"config_file":            *configFile,
+			"cypress_docker_version": *cypressDockerVersion,
+			"triggered_by":           *triggeredBy,
+		} {
+			if value != "" {
+				form.Set(key, value)
+			}
+		}
+		if *maxPods > 0 {
+			form.Set("maxPods", strconv.Itoa(*maxPods))
+		}
+
+		var err error
+		*uniqID, err = c.launch(form)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error occured while launching project %s: %s\n", *project, err.Error())
+			return exitError
+		}
+		fmt.Fprintf(stdout, "Run %s of project %s launched\n", *uniqID, *project)
+	}
+
+	v, err := c.wait(*uniqID, *timeout)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error occured while waiting for run %s: %s\n", *uniqID, err.Error())
+		return exitError
+	}
+	report(stdout, *uniqID, v)
+	return v.ExitCode
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
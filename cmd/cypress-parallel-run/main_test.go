@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeAPI return an api answering launches with the uniq id abc which is done
+// after being waited for twice with the exit code provided
+func newFakeAPI(t *testing.T, exitCode int) (*httptest.Server, *[]string) {
+	var requests []string
+	waited := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.String())
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/cypress-parallel-api/hooks/launch/plain":
+			assert.NoError(t, r.ParseForm())
+			if r.PostForm.Get("project_name") == "fake" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"Project fake not found"}`)
+				return
+			}
+			assert.Equal(t, "cli", r.PostForm.Get("triggered_by"))
+			assert.Equal(t, "v1.0.0", r.PostForm.Get("commit"))
+			assert.Empty(t, r.PostForm.Get("branch"))
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"uniqId":"abc","statusUrl":"http://127.0.0.1:8080/api/v1/cypress-parallel-api/runs/abc"}`)
+		case "/api/v1/cypress-parallel-api/runs/abc/wait":
+			waited++
+			if waited < 2 {
+				fmt.Fprint(w, `{"run":{"status":"RUNNING"},"done":false,"exitCode":3,"summary":{"total":2,"RUNNING":2},"specs":[]}`)
+				return
+			}
+			fmt.Fprintf(w, `{"run":{"status":"FAILED"},"done":true,"exitCode":%d,"summary":{"total":2,"DONE":1,"FAILED":1},"specs":[{"spec":"a.cy.js","execution_status":"DONE","duration":"12.5"},{"spec":"b.cy.js","execution_status":"FAILED","duration":""}]}`, exitCode)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return srv, &requests
+}
+
+func TestRun(t *testing.T) {
+	assert := assert.New(t)
+
+	srv, requests := newFakeAPI(t, 1)
+	defer srv.Close()
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(1, run([]string{"-api-url", srv.URL + "/", "-project", "kitchensink", "-commit", "v1.0.0", "-timeout", "10s"}, &stdout, &stderr))
+	assert.Empty(stderr.String())
+	assert.Equal([]string{
+		"/api/v1/cypress-parallel-api/hooks/launch/plain",
+		"/api/v1/cypress-parallel-api/runs/abc/wait?timeout=9",
+		"/api/v1/cypress-parallel-api/runs/abc/wait?timeout=9",
+	}, *requests)
+	assert.Contains(stdout.String(), "Run abc of project kitchensink launched")
+	assert.Contains(stdout.String(), "DONE        a.cy.js (12.5s)")
+	assert.Contains(stdout.String(), "FAILED      b.cy.js\n")
+	assert.Contains(stdout.String(), "Run abc is FAILED, 2 specs: 1 DONE, 1 FAILED")
+}
+
+func TestRun_uniqID(t *testing.T) {
+	assert := assert.New(t)
+
+	srv, requests := newFakeAPI(t, 0)
+	defer srv.Close()
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(0, run([]string{"-api-url", srv.URL, "-uniq-id", "abc"}, &stdout, &stderr))
+	assert.Equal([]string{
+		"/api/v1/cypress-parallel-api/runs/abc/wait?timeout=600",
+		"/api/v1/cypress-parallel-api/runs/abc/wait?timeout=600",
+	}, *requests)
+}
+
+func TestRun_fail(t *testing.T) {
+	assert := assert.New(t)
+
+	srv, _ := newFakeAPI(t, 0)
+	defer srv.Close()
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(exitError, run([]string{"-api-url", srv.URL}, &stdout, &stderr))
+	assert.Contains(stderr.String(), "One of -project or -uniq-id is required")
+
+	stderr.Reset()
+	assert.Equal(exitError, run([]string{"-api-url", srv.URL, "-project", "fake"}, &stdout, &stderr))
+	assert.Contains(stderr.String(), "Project fake not found")
+
+	stderr.Reset()
+	assert.Equal(exitError, run([]string{"-api-url", srv.URL, "-uniq-id", "fake"}, &stdout, &stderr))
+	assert.Contains(stderr.String(), "Api answered 404")
+}
@@ -3,8 +3,10 @@ package commons
 
 import (
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
@@ -69,3 +71,205 @@ func GetAPIUrl() string {
 		return z
 	}
 }
+
+// GetLaunchWorkers permit to retrieve OS env variable
+// It is the number of launches cloning repositories and creating pods at the same time
+func GetLaunchWorkers() int {
+	harcoded := 4
+	workers := strings.TrimSpace(os.Getenv("CYPRESS_PARALLEL_API_LAUNCH_WORKERS"))
+	if workers == "" {
+		return harcoded
+	}
+	m, err := strconv.Atoi(workers)
+	if err != nil || m <= 0 {
+		log.Error().Err(err).Msgf("Error occured while converting string to int so let's set it to %d anyway", harcoded)
+		return harcoded
+	}
+	return m
+}
+
+// GetLaunchTimeout permit to retrieve OS env variable
+// It is the time after which runs still LAUNCHING, like the ones of a crashed api, are marked as FAILED
+func GetLaunchTimeout() time.Duration {
+	return getDuration("CYPRESS_PARALLEL_API_LAUNCH_TIMEOUT", 15*time.Minute)
+}
+
+// getDuration permit to retrieve OS env variable as a duration
+// or return the harcoded value when not set or invalid
+func getDuration(env string, harcoded time.Duration) time.Duration {
+	z := strings.TrimSpace(os.Getenv(env))
+	if z == "" {
+		return harcoded
+	}
+	d, err := time.ParseDuration(z)
+	if err != nil || d <= 0 {
+		log.Error().Err(err).Msgf("Error occured while converting %s to duration so let's set it to %s anyway", env, harcoded)
+		return harcoded
+	}
+	return d
+}
+
+// GetReaperGracePeriod permit to retrieve OS env variable
+// It is the extra time given to executions on top of the project timeout
+// before they are considered as stuck
+func GetReaperGracePeriod() time.Duration {
+	return getDuration("CYPRESS_PARALLEL_API_REAPER_GRACE_PERIOD", 5*time.Minute)
+}
+
+// GetReaperInterval permit to retrieve OS env variable
+// It is the interval at which stuck executions are checked
+func GetReaperInterval() time.Duration {
+	return getDuration("CYPRESS_PARALLEL_API_REAPER_INTERVAL", time.Minute)
+}
+
+// GetKubernetesJobsBackoffLimit permit to retrieve OS env variable
+// It is the number of retries of kubernetes jobs before they are marked as failed
+func GetKubernetesJobsBackoffLimit() int32 {
+	harcoded := int32(2)
+	limit := strings.TrimSpace(os.Getenv("CYPRESS_PARALLEL_API_JOBS_BACKOFF_LIMIT"))
+	if limit == "" {
+		return harcoded
+	}
+	m, err := strconv.Atoi(limit)
+	if err != nil || m < 0 {
+		log.Error().Err(err).Msgf("Error occured while converting string to int so let's set it to %d anyway", harcoded)
+		return harcoded
+	}
+	return int32(m)
+}
+
+// GetKubernetesJobsTTL permit to retrieve OS env variable
+// It is the time finished kubernetes jobs are kept before being deleted
+func GetKubernetesJobsTTL() time.Duration {
+	return getDuration("CYPRESS_PARALLEL_API_JOBS_TTL", time.Hour)
+}
+
+// getString permit to retrieve OS env variable
+// or return the harcoded value when not set
+func getString(env string, harcoded string) string {
+	z := strings.TrimSpace(os.Getenv(env))
+	if z == "" {
+		return harcoded
+	}
+	return z
+}
+
+// GetKubernetesJobsCPURequest permit to retrieve OS env variable
+// It is the default CPU request of pods when not set in the project
+func GetKubernetesJobsCPURequest() string {
+	return getString("CYPRESS_PARALLEL_API_JOBS_CPU_REQUEST", "500m")
+}
+
+// GetKubernetesJobsCPULimit permit to retrieve OS env variable
+// It is the default CPU limit of pods when not set in the project
+func GetKubernetesJobsCPULimit() string {
+	return getString("CYPRESS_PARALLEL_API_JOBS_CPU_LIMIT", "")
+}
+
+// GetKubernetesJobsMemoryRequest permit to retrieve OS env variable
+// It is the default memory request of pods when not set in the project
+func GetKubernetesJobsMemoryRequest() string {
+	return getString("CYPRESS_PARALLEL_API_JOBS_MEMORY_REQUEST", "1Gi")
+}
+
+// GetKubernetesJobsMemoryLimit permit to retrieve OS env variable
+// It is the default memory limit of pods when not set in the project
+func GetKubernetesJobsMemoryLimit() string {
+	return getString("CYPRESS_PARALLEL_API_JOBS_MEMORY_LIMIT", "")
+}
+
+// GetKubernetesJobsNodeSelector permit to retrieve OS env variable
+// It is the default json node selector of pods when not set in the project
+func GetKubernetesJobsNodeSelector() string {
+	return getString("CYPRESS_PARALLEL_API_JOBS_NODE_SELECTOR", "")
+}
+
+// GetKubernetesJobsTolerations permit to retrieve OS env variable
+// It is the default json tolerations of pods when not set in the project
+func GetKubernetesJobsTolerations() string {
+	return getString("CYPRESS_PARALLEL_API_JOBS_TOLERATIONS", "")
+}
+
+// GetKubernetesJobsAffinity permit to retrieve OS env variable
+// It is the default json affinity of pods when not set in the project
+func GetKubernetesJobsAffinity() string {
+	return getString("CYPRESS_PARALLEL_API_JOBS_AFFINITY", "")
+}
+
+// GetKubernetesJobsImageRepository permit to retrieve OS env variable
+// It is the default image repository of pods when not set in the project
+// and the cypress docker version is used as tag
+func GetKubernetesJobsImageRepository() string {
+	return getString("CYPRESS_PARALLEL_API_JOBS_IMAGE_REPOSITORY", "docker.pkg.github.com/lord-y/cypress-parallel-docker-images/cypress-parallel-docker-images")
+}
+
+// GetKubernetesJobsImagePullSecrets permit to retrieve OS env variable
+// It is the comma separated list of image pull secrets attached to pods and their service account
+func GetKubernetesJobsImagePullSecrets() []string {
+	return SplitList(os.Getenv("CYPRESS_PARALLEL_API_JOBS_IMAGE_PULL_SECRETS"))
+}
+
+// GetGitSSHKnownHosts permit to retrieve OS env variable
+// It is the default known_hosts content used to verify SSH host keys of git repositories
+// when not set in the project
+func GetGitSSHKnownHosts() string {
+	return getString("CYPRESS_PARALLEL_API_GIT_SSH_KNOWN_HOSTS", "")
+}
+
+// GetGitCacheDir permit to retrieve OS env variable
+// It is the directory in which git repositories mirrors are cached
+func GetGitCacheDir() string {
+	return getString("CYPRESS_PARALLEL_API_GIT_CACHE_DIR", filepath.Join(os.TempDir(), "cypress-parallel-api-git-cache"))
+}
+
+// GetGitCacheMaxAge permit to retrieve OS env variable
+// It is the time after which git repositories mirrors not used are evicted from the cache
+func GetGitCacheMaxAge() time.Duration {
+	return getDuration("CYPRESS_PARALLEL_API_GIT_CACHE_MAX_AGE", 7*24*time.Hour)
+}
+
+// GetGitCacheEvictionInterval permit to retrieve OS env variable
+// It is the interval at which git repositories mirrors are checked for eviction
+func GetGitCacheEvictionInterval() time.Duration {
+	return getDuration("CYPRESS_PARALLEL_API_GIT_CACHE_EVICTION_INTERVAL", 10*time.Minute)
+}
+
+// GetGitCacheMaxSize permit to retrieve OS env variable
+// It is the size in megabytes above which least recently used git repositories mirrors
+// are evicted from the cache
+func GetGitCacheMaxSize() int64 {
+	harcoded := int64(5120)
+	size := strings.TrimSpace(os.Getenv("CYPRESS_PARALLEL_API_GIT_CACHE_MAX_SIZE"))
+	if size == "" {
+		return harcoded
+	}
+	m, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || m <= 0 {
+		log.Error().Err(err).Msgf("Error occured while converting string to int so let's set it to %d anyway", harcoded)
+		return harcoded
+	}
+	return m
+}
+
+// GetEncryptionKeys permit to retrieve OS env variable
+// It is the comma separated list of id:base64 encoded keys used to encrypt secret columns.
+// The first key is used to encrypt, all of them are used to decrypt
+func GetEncryptionKeys() string {
+	return getString("CYPRESS_PARALLEL_API_ENCRYPTION_KEYS", "")
+}
+
+// GetEncryptionKeysFile permit to retrieve OS env variable
+// It is the path of a file holding encryption keys with the same format, one or more per line
+func GetEncryptionKeysFile() string {
+	return getString("CYPRESS_PARALLEL_API_ENCRYPTION_KEYS_FILE", "")
+}
+
+// SplitList return the non empty trimmed values of the comma separated list provided
+func SplitList(list string) (z []string) {
+	for _, v := range strings.Split(list, ",") {
+		if strings.TrimSpace(v) != "" {
+			z = append(z, strings.TrimSpace(v))
+		}
+	}
+	return
+}
@@ -0,0 +1,96 @@
+// Package commons assemble all functions used in other packages
+package commons
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLaunchTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	os.Unsetenv("CYPRESS_PARALLEL_API_LAUNCH_TIMEOUT")
+	assert.Equal(15*time.Minute, GetLaunchTimeout())
+
+	os.Setenv("CYPRESS_PARALLEL_API_LAUNCH_TIMEOUT", "30m")
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_LAUNCH_TIMEOUT")
+	assert.Equal(30*time.Minute, GetLaunchTimeout())
+}
+
+func TestGetReaperGracePeriod(t *testing.T) {
+	assert := assert.New(t)
+
+	os.Unsetenv("CYPRESS_PARALLEL_API_REAPER_GRACE_PERIOD")
+	assert.Equal(5*time.Minute, GetReaperGracePeriod())
+
+	os.Setenv("CYPRESS_PARALLEL_API_REAPER_GRACE_PERIOD", "90s")
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_REAPER_GRACE_PERIOD")
+	assert.Equal(90*time.Second, GetReaperGracePeriod())
+
+	os.Setenv("CYPRESS_PARALLEL_API_REAPER_GRACE_PERIOD", "fake")
+	assert.Equal(5*time.Minute, GetReaperGracePeriod())
+}
+
+func TestGetReaperInterval(t *testing.T) {
+	assert := assert.New(t)
+
+	os.Unsetenv("CYPRESS_PARALLEL_API_REAPER_INTERVAL")
+	assert.Equal(time.Minute, GetReaperInterval())
+
+	os.Setenv("CYPRESS_PARALLEL_API_REAPER_INTERVAL", "-1m")
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_REAPER_INTERVAL")
+	assert.Equal(time.Minute, GetReaperInterval())
+}
+
+func TestGetGitCacheEvictionInterval(t *testing.T) {
+	assert := assert.New(t)
+
+	os.Unsetenv("CYPRESS_PARALLEL_API_GIT_CACHE_EVICTION_INTERVAL")
+	assert.Equal(10*time.Minute, GetGitCacheEvictionInterval())
+
+	os.Setenv("CYPRESS_PARALLEL_API_GIT_CACHE_EVICTION_INTERVAL", "1h")
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_GIT_CACHE_EVICTION_INTERVAL")
+	assert.Equal(time.Hour, GetGitCacheEvictionInterval())
+}
+
+func TestGetKubernetesJobsBackoffLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	os.Unsetenv("CYPRESS_PARALLEL_API_JOBS_BACKOFF_LIMIT")
+	assert.Equal(int32(2), GetKubernetesJobsBackoffLimit())
+
+	os.Setenv("CYPRESS_PARALLEL_API_JOBS_BACKOFF_LIMIT", "0")
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_JOBS_BACKOFF_LIMIT")
+	assert.Equal(int32(0), GetKubernetesJobsBackoffLimit())
+
+	os.Setenv("CYPRESS_PARALLEL_API_JOBS_BACKOFF_LIMIT", "fake")
+	assert.Equal(int32(2), GetKubernetesJobsBackoffLimit())
+}
+
+func TestGetKubernetesJobsImagePullSecrets(t *testing.T) {
+	assert := assert.New(t)
+
+	os.Unsetenv("CYPRESS_PARALLEL_API_JOBS_IMAGE_PULL_SECRETS")
+	assert.Empty(GetKubernetesJobsImagePullSecrets())
+
+	os.Setenv("CYPRESS_PARALLEL_API_JOBS_IMAGE_PULL_SECRETS", " registry , ,mirror")
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_JOBS_IMAGE_PULL_SECRETS")
+	assert.Equal([]string{"registry", "mirror"}, GetKubernetesJobsImagePullSecrets())
+}
+
+func TestGetLaunchWorkers(t *testing.T) {
+	assert := assert.New(t)
+
+	os.Unsetenv("CYPRESS_PARALLEL_API_LAUNCH_WORKERS")
+	assert.Equal(4, GetLaunchWorkers())
+
+	os.Setenv("CYPRESS_PARALLEL_API_LAUNCH_WORKERS", "8")
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_LAUNCH_WORKERS")
+	assert.Equal(8, GetLaunchWorkers())
+
+	os.Setenv("CYPRESS_PARALLEL_API_LAUNCH_WORKERS", "0")
+	assert.Equal(4, GetLaunchWorkers())
+}
@@ -0,0 +1,307 @@
+// Package discovery will manage all requirements to find specs in repositories
+package discovery
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	// DefaultInclude are the patterns of Cypress 10 and former Cypress specs
+	// used when the project has no include patterns.
+	// Cypress 9 and former run all files of the integration folder whatever their name
+	DefaultInclude = []string{
+		"**/*.cy.{js,jsx,ts,tsx}",
+		"**/*.spec.{js,jsx,ts,tsx}",
+		"cypress/integration/**/*.{js,jsx,ts,tsx}",
+	}
+	// DefaultExclude are the patterns of files never considered as specs
+	// used when the project has no exclude patterns
+	DefaultExclude = []string{
+		"**/node_modules/**",
+	}
+)
+
+// SplitPatterns return the non empty trimmed patterns of the comma or newline separated list provided.
+// Commas inside braces are part of the pattern
+func SplitPatterns(list string) (z []string) {
+	var (
+		depth   int
+		current strings.Builder
+	)
+	flush := func() {
+		if strings.TrimSpace(current.String()) != "" {
+			z = append(z, strings.TrimSpace(current.String()))
+		}
+		current.Reset()
+	}
+	for _, r := range list {
+		switch {
+		case r == '{':
+			depth++
+		case r == '}' && depth > 0:
+			depth--
+		case (r == ',' && depth == 0) || r == '\n':
+			flush()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	flush()
+	return
+}
+
+// expandBraces return all the patterns described by the braces of the pattern provided
+// like a/{b,c}/d which is expanded to a/b/d and a/c/d
+func expandBraces(pattern string) (z []string, err error) {
+	start := strings.Index(pattern, "{")
+	if start < 0 {
+		if strings.Contains(pattern, "}") {
+			return nil, fmt.Errorf("Pattern %s has unbalanced braces", pattern)
+		}
+		return []string{pattern}, nil
+	}
+
+	var (
+		depth        int
+		alternatives []string
+		last         = start + 1
+		end          = -1
+	)
+	for i := start; i < len(pattern) && end < 0; i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				alternatives = append(alternatives, pattern[last:i])
+				end = i
+			}
+		case ',':
+			if depth == 1 {
+				alternatives = append(alternatives, pattern[last:i])
+				last = i + 1
+			}
+		}
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("Pattern %s has unbalanced braces", pattern)
+	}
+
+	for _, alternative := range alternatives {
+		expanded, err := expandBraces(pattern[:start] + alternative + pattern[end+1:])
+		if err != nil {
+			return nil, err
+		}
+		z = append(z, expanded...)
+	}
+	return z, nil
+}
+
+// ValidatePatterns return an error when one of the patterns provided is malformed,
+// is absolute or try to leave the repository
+func ValidatePatterns(patterns []string) (err error) {
+	for _, pattern := range patterns {
+		expanded, err := expandBraces(pattern)
+		if err != nil {
+			return err
+		}
+		for _, p := range expanded {
+			if path.IsAbs(p) || filepath.IsAbs(p) {
+				return fmt.Errorf("Pattern %s must be relative to the repository", pattern)
+			}
+			for _, segment := range strings.Split(p, "/") {
+				if segment == ".." {
+					return fmt.Errorf("Pattern %s must not leave the repository", pattern)
+				}
+				if _, err := path.Match(segment, ""); err != nil {
+					return fmt.Errorf("Pattern %s is malformed: %s", pattern, err.Error())
+				}
+			}
+		}
+	}
+	return
+}
+
+// Match return true when the slash separated name matches the pattern provided.
+// Besides path.Match syntax, ** matches any number of directories and {a,b} matches a or b
+func Match(pattern string, name string) bool {
+	expanded, err := expandBraces(pattern)
+	if err != nil {
+		return false
+	}
+	for _, p := range expanded {
+		if matchSegments(strings.Split(p, "/"), strings.Split(name, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments return true when the name segments match the pattern segments
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// collapse consecutive ** and try to match the rest at every depth
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		matched, err := path.Match(pattern[0], name[0])
+		if err != nil || !matched {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchAny return true when the name matches one of the patterns provided
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if Match(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve return the absolute path of dir inside of root
+// or an error when it leaves root, symlinks included
+func resolve(root string, dir string) (z string, err error) {
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return
+	}
+	z, err = filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(dir)))
+	if err != nil {
+		return
+	}
+	rel, err := filepath.Rel(root, z)
+	if err != nil {
+		return
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("Specs %s must not leave the repository", dir)
+	}
+	return z, nil
+}
+
+// Find return the sorted paths, relative to root, of the specs found in the file or directory dir of root.
+// A file is returned as is while files of a directory are returned when they match one of the include patterns
+// and none of the exclude ones. Patterns are matched against paths relative to root
+// and default ones are used when none are provided
+func Find(root string, dir string, include []string, exclude []string) (z []string, err error) {
+	if len(include) == 0 {
+		include = DefaultInclude
+	}
+	if len(exclude) == 0 {
+		exclude = DefaultExclude
+	}
+	for _, patterns := range [][]string{include, exclude} {
+		if err = ValidatePatterns(patterns); err != nil {
+			return
+		}
+	}
+
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return
+	}
+	target, err := resolve(root, dir)
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return
+	}
+	if info.Mode().IsRegular() {
+		rel, err := filepath.Rel(resolvedRoot, target)
+		if err != nil {
+			return nil, err
+		}
+		return []string{filepath.ToSlash(rel)}, nil
+	}
+
+	err = filepath.Walk(target, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(resolvedRoot, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if matchAny(include, rel) && !matchAny(exclude, rel) {
+			z = append(z, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	sort.Strings(z)
+	return z, nil
+}
+
+// Node is a directory or a spec of a specs tree
+type Node struct {
+	Name     string  `json:"name"`
+	Path     string  `json:"path"`
+	Type     string  `json:"type"` // directory or spec
+	Children []*Node `json:"children,omitempty"`
+}
+
+// Tree return the tree of directories holding the slash separated specs provided.
+// Nodes are kept in the order of specs
+func Tree(specs []string) (z []*Node) {
+	for _, spec := range specs {
+		var (
+			nodes    = &z
+			segments = strings.Split(spec, "/")
+		)
+		for i, segment := range segments {
+			var node *Node
+			for _, n := range *nodes {
+				if n.Name == segment {
+					node = n
+				}
+			}
+			if node == nil {
+				node = &Node{
+					Name: segment,
+					Path: strings.Join(segments[:i+1], "/"),
+					Type: "directory",
+				}
+				if i == len(segments)-1 {
+					node.Type = "spec"
+				}
+				*nodes = append(*nodes, node)
+			}
+			nodes = &node.Children
+		}
+	}
+	return
+}
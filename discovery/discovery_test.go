@@ -0,0 +1,138 @@
+// Package discovery will manage all requirements to find specs in repositories
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitPatterns(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(SplitPatterns(""))
+	assert.Equal([]string{"**/*.cy.{js,ts}", "cypress/e2e/**"}, SplitPatterns(" **/*.cy.{js,ts} ,\ncypress/e2e/**\n"))
+}
+
+func TestMatch(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"**/*.cy.{js,ts}", "cypress/e2e/login.cy.ts", true},
+		{"**/*.cy.{js,ts}", "login.cy.js", true},
+		{"**/*.cy.{js,ts}", "cypress/e2e/login.cy.tsx", false},
+		{"cypress/e2e/**/*.cy.js", "cypress/e2e/admin/users/list.cy.js", true},
+		{"cypress/e2e/**/*.cy.js", "cypress/integration/list.cy.js", false},
+		{"cypress/*/*.spec.js", "cypress/integration/list.spec.js", true},
+		{"cypress/*/*.spec.js", "cypress/integration/admin/list.spec.js", false},
+		{"**/node_modules/**", "node_modules/lib/index.spec.js", true},
+		{"**/support/**", "cypress/support/commands.ts", true},
+		{"**/{a,b{c,d}}.js", "x/bd.js", true},
+		{"**/[ab].js", "c.js", false},
+		{"**/{a.js", "a.js", false},
+	}
+	for _, tc := range tests {
+		assert.Equal(tc.match, Match(tc.pattern, tc.name), "%s %s", tc.pattern, tc.name)
+	}
+}
+
+func TestValidatePatterns(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(ValidatePatterns(DefaultInclude))
+	assert.NoError(ValidatePatterns(DefaultExclude))
+	assert.Error(ValidatePatterns([]string{"/etc/**"}))
+	assert.Error(ValidatePatterns([]string{"../**/*.js"}))
+	assert.Error(ValidatePatterns([]string{"cypress/{e2e,..}/*.js"}))
+	assert.Error(ValidatePatterns([]string{"cypress/[a.js"}))
+	assert.Error(ValidatePatterns([]string{"cypress/{a.js"}))
+}
+
+func TestFind(t *testing.T) {
+	assert := assert.New(t)
+
+	root := t.TempDir()
+	for _, file := range []string{
+		"cypress/e2e/login.cy.ts",
+		"cypress/e2e/admin/users.cy.js",
+		"cypress/integration/legacy.spec.js",
+		"cypress/integration/home.js",
+		"cypress/integration/examples/actions.ts",
+		"cypress/support/commands.ts",
+		"cypress/support/helpers.js",
+		"node_modules/lib/index.spec.js",
+	} {
+		assert.NoError(os.MkdirAll(filepath.Join(root, filepath.Dir(file)), 0755))
+		assert.NoError(os.WriteFile(filepath.Join(root, file), []byte("spec"), 0644))
+	}
+
+	z, err := Find(root, "", nil, nil)
+	assert.NoError(err)
+	assert.Equal([]string{"cypress/e2e/admin/users.cy.js", "cypress/e2e/login.cy.ts", "cypress/integration/examples/actions.ts", "cypress/integration/home.js", "cypress/integration/legacy.spec.js"}, z)
+
+	z, err = Find(root, "cypress/e2e", nil, nil)
+	assert.NoError(err)
+	assert.Equal([]string{"cypress/e2e/admin/users.cy.js", "cypress/e2e/login.cy.ts"}, z)
+
+	z, err = Find(root, "cypress", []string{"**/*.ts"}, []string{"cypress/support/**"})
+	assert.NoError(err)
+	assert.Equal([]string{"cypress/e2e/login.cy.ts", "cypress/integration/examples/actions.ts"}, z)
+
+	z, err = Find(root, "cypress/support/helpers.js", nil, nil)
+	assert.NoError(err)
+	assert.Equal([]string{"cypress/support/helpers.js"}, z)
+
+	_, err = Find(root, "cypress/fake", nil, nil)
+	assert.Error(err)
+
+	_, err = Find(root, "../", nil, nil)
+	assert.Error(err)
+
+	outside := t.TempDir()
+	assert.NoError(os.Symlink(outside, filepath.Join(root, "outside")))
+	_, err = Find(root, "outside", nil, nil)
+	assert.Error(err)
+
+	_, err = Find(root, "", []string{"../**"}, nil)
+	assert.Error(err)
+}
+
+func TestTree(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(Tree(nil))
+
+	z := Tree([]string{"cypress/e2e/admin/users.cy.js", "cypress/e2e/login.cy.ts", "home.cy.js"})
+	assert.Equal([]*Node{
+		{
+			Name: "cypress",
+			Path: "cypress",
+			Type: "directory",
+			Children: []*Node{
+				{
+					Name: "e2e",
+					Path: "cypress/e2e",
+					Type: "directory",
+					Children: []*Node{
+						{
+							Name: "admin",
+							Path: "cypress/e2e/admin",
+							Type: "directory",
+							Children: []*Node{
+								{Name: "users.cy.js", Path: "cypress/e2e/admin/users.cy.js", Type: "spec"},
+							},
+						},
+						{Name: "login.cy.ts", Path: "cypress/e2e/login.cy.ts", Type: "spec"},
+					},
+				},
+			},
+		},
+		{Name: "home.cy.js", Path: "home.cy.js", Type: "spec"},
+	}, z)
+}
@@ -0,0 +1,198 @@
+// Package encryption will manage all requirements to encrypt secret columns
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Lord-Y/cypress-parallel-api/commons"
+)
+
+// prefix is added to all encrypted values so they can be distinguished
+// from plaintext values stored before encryption was enabled
+const prefix = "enc:v1:"
+
+// key struct handle an encryption key and its id
+type key struct {
+	id    string
+	value []byte
+}
+
+// keys return the keys set in environment variable followed by the ones of the keys file.
+// The first one is the primary key used to encrypt
+func keys() (z []key, err error) {
+	list := commons.GetEncryptionKeys()
+	if commons.GetEncryptionKeysFile() != "" {
+		content, err := ioutil.ReadFile(commons.GetEncryptionKeysFile())
+		if err != nil {
+			return z, err
+		}
+		list = fmt.Sprintf("%s\n%s", list, content)
+	}
+	return parseKeys(list)
+}
+
+// parseKeys return keys of the comma or newline separated list of id:base64 encoded keys
+func parseKeys(list string) (z []key, err error) {
+	ids := make(map[string]bool)
+	for _, v := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		v = strings.TrimSpace(v)
+		if v == "" || strings.HasPrefix(v, "#") {
+			continue
+		}
+		parts := strings.SplitN(v, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Encryption key must be formatted as id:base64 encoded key")
+		}
+		if ids[parts[0]] {
+			return nil, fmt.Errorf("Encryption key id %s is duplicated", parts[0])
+		}
+		value, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("Encryption key %s is not base64 encoded: %s", parts[0], err.Error())
+		}
+		if len(value) != 32 {
+			return nil, fmt.Errorf("Encryption key %s must be 32 bytes long", parts[0])
+		}
+		ids[parts[0]] = true
+		z = append(z, key{id: parts[0], value: value})
+	}
+	return
+}
+
+// seal permit to encrypt plaintext with AES-GCM and return the nonce followed by the ciphertext
+func seal(k []byte, plaintext []byte, data []byte) (z []byte, err error) {
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return
+	}
+	return gcm.Seal(nonce, nonce, plaintext, data), nil
+}
+
+// open permit to decrypt ciphertext returned by seal
+func open(k []byte, ciphertext []byte, data []byte) (z []byte, err error) {
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return z, fmt.Errorf("Ciphertext is too short")
+	}
+	return gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], data)
+}
+
+// IsEncrypted return true when the value has been encrypted by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Enabled return true when at least one encryption key is set
+func Enabled() (bool, error) {
+	z, err := keys()
+	return len(z) > 0, err
+}
+
+// Encrypt permit to encrypt the value with a random data key which is itself encrypted
+// with the primary key. The value is returned as is when it's empty or when no keys are set
+func Encrypt(value string) (z string, err error) {
+	ks, err := keys()
+	if err != nil {
+		return
+	}
+	if value == "" || len(ks) == 0 {
+		return value, nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err = io.ReadFull(rand.Reader, dataKey); err != nil {
+		return
+	}
+	wrapped, err := seal(ks[0].value, dataKey, []byte(ks[0].id))
+	if err != nil {
+		return
+	}
+	ciphertext, err := seal(dataKey, []byte(value), nil)
+	if err != nil {
+		return
+	}
+	return fmt.Sprintf(
+		"%s%s:%s:%s",
+		prefix,
+		ks[0].id,
+		base64.StdEncoding.EncodeToString(wrapped),
+		base64.StdEncoding.EncodeToString(ciphertext),
+	), nil
+}
+
+// Decrypt permit to decrypt the value encrypted by Encrypt with any of the keys set.
+// Plaintext values are returned as is
+func Decrypt(value string) (z string, err error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return z, fmt.Errorf("Encrypted value is malformed")
+	}
+
+	ks, err := keys()
+	if err != nil {
+		return
+	}
+	var k *key
+	for i := range ks {
+		if ks[i].id == parts[0] {
+			k = &ks[i]
+			break
+		}
+	}
+	if k == nil {
+		return z, fmt.Errorf("Encryption key %s is not set", parts[0])
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return
+	}
+	dataKey, err := open(k.value, wrapped, []byte(k.id))
+	if err != nil {
+		return
+	}
+	plaintext, err := open(dataKey, ciphertext, nil)
+	if err != nil {
+		return
+	}
+	return string(plaintext), nil
+}
+
+// Stale return true when the value is not encrypted with the primary key
+// and must be re-encrypted
+func Stale(value string) (bool, error) {
+	ks, err := keys()
+	if err != nil || value == "" || len(ks) == 0 {
+		return false, err
+	}
+	return !strings.HasPrefix(value, fmt.Sprintf("%s%s:", prefix, ks[0].id)), nil
+}
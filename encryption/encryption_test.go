@@ -0,0 +1,144 @@
+// Package encryption will manage all requirements to encrypt secret columns
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newKey return a random base64 encoded key
+func newKey(t *testing.T) string {
+	k := make([]byte, 32)
+	if _, err := rand.Read(k); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(k)
+}
+
+func TestEncrypt_no_keys(t *testing.T) {
+	assert := assert.New(t)
+
+	enabled, err := Enabled()
+	assert.NoError(err)
+	assert.False(enabled)
+
+	z, err := Encrypt("password")
+	assert.NoError(err)
+	assert.Equal("password", z)
+
+	z, err = Decrypt("password")
+	assert.NoError(err)
+	assert.Equal("password", z)
+
+	_, err = Decrypt("enc:v1:k1:fake:fake")
+	assert.Error(err)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	assert := assert.New(t)
+
+	os.Setenv("CYPRESS_PARALLEL_API_ENCRYPTION_KEYS", fmt.Sprintf("k1:%s", newKey(t)))
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_ENCRYPTION_KEYS")
+
+	z, err := Encrypt("")
+	assert.NoError(err)
+	assert.Empty(z)
+
+	encrypted, err := Encrypt("password")
+	assert.NoError(err)
+	assert.True(IsEncrypted(encrypted))
+	assert.NotContains(encrypted, "password")
+
+	other, err := Encrypt("password")
+	assert.NoError(err)
+	assert.NotEqual(encrypted, other)
+
+	z, err = Decrypt(encrypted)
+	assert.NoError(err)
+	assert.Equal("password", z)
+
+	z, err = Decrypt("plaintext")
+	assert.NoError(err)
+	assert.Equal("plaintext", z)
+
+	_, err = Decrypt(encrypted[:len(encrypted)-4])
+	assert.Error(err)
+}
+
+func TestEncrypt_rotation(t *testing.T) {
+	assert := assert.New(t)
+
+	k1 := fmt.Sprintf("k1:%s", newKey(t))
+	k2 := fmt.Sprintf("k2:%s", newKey(t))
+
+	os.Setenv("CYPRESS_PARALLEL_API_ENCRYPTION_KEYS", k1)
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_ENCRYPTION_KEYS")
+	encrypted, err := Encrypt("password")
+	assert.NoError(err)
+
+	stale, err := Stale(encrypted)
+	assert.NoError(err)
+	assert.False(stale)
+
+	// k2 is the new primary key, k1 is kept in the keys file to decrypt former values
+	file, err := ioutil.TempFile("", "keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(fmt.Sprintf("# former keys\n%s\n", k1))
+	assert.NoError(err)
+	file.Close()
+
+	os.Setenv("CYPRESS_PARALLEL_API_ENCRYPTION_KEYS", k2)
+	os.Setenv("CYPRESS_PARALLEL_API_ENCRYPTION_KEYS_FILE", file.Name())
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_ENCRYPTION_KEYS_FILE")
+
+	stale, err = Stale(encrypted)
+	assert.NoError(err)
+	assert.True(stale)
+	stale, err = Stale("plaintext")
+	assert.NoError(err)
+	assert.True(stale)
+
+	z, err := Decrypt(encrypted)
+	assert.NoError(err)
+	assert.Equal("password", z)
+
+	reencrypted, err := Encrypt(z)
+	assert.NoError(err)
+	stale, err = Stale(reencrypted)
+	assert.NoError(err)
+	assert.False(stale)
+
+	// k1 removed, values encrypted with it can't be decrypted anymore
+	os.Unsetenv("CYPRESS_PARALLEL_API_ENCRYPTION_KEYS_FILE")
+	_, err = Decrypt(encrypted)
+	assert.Error(err)
+}
+
+func TestParseKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	z, err := parseKeys(fmt.Sprintf("k1:%s, k2:%s\n\n", newKey(t), newKey(t)))
+	assert.NoError(err)
+	assert.Len(z, 2)
+	assert.Equal("k1", z[0].id)
+
+	for _, list := range []string{
+		"fake",
+		fmt.Sprintf(":%s", newKey(t)),
+		"k1:fake",
+		fmt.Sprintf("k1:%s", base64.StdEncoding.EncodeToString([]byte("short"))),
+		fmt.Sprintf("k1:%s,k1:%s", newKey(t), newKey(t)),
+	} {
+		_, err = parseKeys(list)
+		assert.Error(err, list)
+	}
+}
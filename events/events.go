@@ -0,0 +1,185 @@
+// Package events will manage all events requirements
+package events
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Lord-Y/cypress-parallel-api/commons"
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// channel is the postgres channel on which events are notified to all api instances
+const channel = "cypress_parallel_events"
+
+// maxPayload is the maximum size in bytes of postgres notifications payload
+const maxPayload = 7999
+
+// subscriberBuffer is the number of events a subscriber can lag behind before missing some
+const subscriberBuffer = 100
+
+// Event is a change of a run or of one of its executions
+type Event struct {
+	Type      string    `json:"type"` // run, execution, pod or result
+	UniqID    string    `json:"uniqId"`
+	ProjectID int       `json:"projectId"`
+	Spec      string    `json:"spec,omitempty"`
+	Status    string    `json:"status,omitempty"` // run status for run events, execution status otherwise
+	PodName   string    `json:"podName,omitempty"`
+	JobName   string    `json:"jobName,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Date      time.Time `json:"date"`
+}
+
+// subscriber hold the events matching its filter
+type subscriber struct {
+	events chan Event
+	filter func(Event) bool
+}
+
+var (
+	subscribersMutex sync.Mutex
+	subscribers      = make(map[*subscriber]bool)
+	listening        sync.Once
+)
+
+// Publish notify the event provided to subscribers of all api instances through postgres.
+// When postgres can't be reached, only subscribers of this instance get it
+func Publish(e Event) {
+	if e.Date.IsZero() {
+		e.Date = time.Now().UTC()
+	}
+	payload, err := encode(e)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while encoding event")
+		return
+	}
+	err = notify(payload)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error occured while notifying event of uniq id %s", e.UniqID)
+		dispatch(e)
+	}
+}
+
+// encode return the json of the event provided with its reason
+// shortened until it fits in a postgres notification
+func encode(e Event) (z string, err error) {
+	for {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return z, err
+		}
+		if len(payload) <= maxPayload || e.Reason == "" {
+			return string(payload), nil
+		}
+		e.Reason = strings.ToValidUTF8(e.Reason[:len(e.Reason)/2], "")
+	}
+}
+
+// notify send the payload provided on the events channel
+func notify(payload string) (err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	_, err = db.Exec("SELECT pg_notify($1, $2)", channel, payload)
+	return
+}
+
+// Subscribe return events matching the filter provided and the function to call once done with them.
+// Events are listened from postgres on first use and are dropped when the subscriber lags behind
+func Subscribe(filter func(Event) bool) (<-chan Event, func()) {
+	listening.Do(listen)
+	return subscribe(filter)
+}
+
+// subscribe register a subscriber of the events matching the filter provided
+func subscribe(filter func(Event) bool) (<-chan Event, func()) {
+	s := &subscriber{
+		events: make(chan Event, subscriberBuffer),
+		filter: filter,
+	}
+	subscribersMutex.Lock()
+	subscribers[s] = true
+	subscribersMutex.Unlock()
+
+	var once sync.Once
+	return s.events, func() {
+		once.Do(func() {
+			subscribersMutex.Lock()
+			delete(subscribers, s)
+			subscribersMutex.Unlock()
+			close(s.events)
+		})
+	}
+}
+
+// dispatch hand the event provided to subscribers matching it
+func dispatch(e Event) {
+	subscribersMutex.Lock()
+	defer subscribersMutex.Unlock()
+	for s := range subscribers {
+		if s.filter != nil && !s.filter(e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			log.Warn().Msgf("Event %s of uniq id %s dropped for a slow subscriber", e.Type, e.UniqID)
+		}
+	}
+}
+
+// listen dispatch events notified by all api instances to subscribers of this one
+func listen() {
+	listener := pq.NewListener(commons.BuildDSN(), time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Error().Err(err).Msg("Error occured while listening to events")
+		}
+	})
+	// Listen blocks until connected so give up waiting for it when postgres can't be reached
+	ready := make(chan struct{})
+	go func() {
+		err := listener.Listen(channel)
+		if err != nil {
+			log.Error().Err(err).Msgf("Error occured while listening to channel %s", channel)
+		}
+		close(ready)
+	}()
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		log.Warn().Msgf("Still not listening to channel %s, events of other instances will be missed meanwhile", channel)
+	}
+
+	go func() {
+		for {
+			select {
+			case n := <-listener.Notify:
+				// nil is sent when the connection was re-established, events notified meanwhile are lost
+				if n == nil {
+					continue
+				}
+				var e Event
+				err := json.Unmarshal([]byte(n.Extra), &e)
+				if err != nil {
+					log.Error().Err(err).Msg("Error occured while decoding event")
+					continue
+				}
+				dispatch(e)
+			case <-time.After(90 * time.Second):
+				// make sure the connection is still alive when nothing happens
+				go listener.Ping()
+			}
+		}
+	}()
+}
@@ -0,0 +1,59 @@
+// Package events will manage all events requirements
+package events
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatch(t *testing.T) {
+	assert := assert.New(t)
+
+	all, unsubscribeAll := subscribe(nil)
+	defer unsubscribeAll()
+	run, unsubscribeRun := subscribe(func(e Event) bool {
+		return e.UniqID == "abc"
+	})
+
+	dispatch(Event{Type: "run", UniqID: "abc", Status: "PENDING"})
+	dispatch(Event{Type: "run", UniqID: "def", Status: "PENDING"})
+	assert.Equal("abc", (<-all).UniqID)
+	assert.Equal("def", (<-all).UniqID)
+	assert.Equal("abc", (<-run).UniqID)
+	assert.Empty(run)
+
+	// unsubscribed subscribers don't get events anymore
+	unsubscribeRun()
+	unsubscribeRun()
+	_, ok := <-run
+	assert.False(ok)
+	dispatch(Event{Type: "run", UniqID: "abc", Status: "LAUNCHING"})
+	assert.Equal("LAUNCHING", (<-all).Status)
+
+	// slow subscribers miss events instead of blocking others
+	for i := 0; i < subscriberBuffer+1; i++ {
+		dispatch(Event{Type: "execution", UniqID: "abc"})
+	}
+	assert.Len(all, subscriberBuffer)
+}
+
+func TestEncode(t *testing.T) {
+	assert := assert.New(t)
+
+	z, err := encode(Event{Type: "result", UniqID: "abc", Status: "FAILED", Reason: "AssertionError"})
+	assert.NoError(err)
+	assert.Contains(z, `"reason":"AssertionError"`)
+
+	// reasons are shortened so events fit in postgres notifications
+	z, err = encode(Event{Type: "result", UniqID: "abc", Status: "FAILED", Reason: strings.Repeat("é", 10000)})
+	assert.NoError(err)
+	assert.LessOrEqual(len(z), maxPayload)
+	var e Event
+	if assert.NoError(json.Unmarshal([]byte(z), &e)) {
+		assert.NotEmpty(e.Reason)
+		assert.Equal("FAILED", e.Status)
+	}
+}
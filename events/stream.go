@@ -0,0 +1,64 @@
+// Package events will manage all events requirements
+package events
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// heartbeat is the time between two comments sent so idle streams are not closed by proxies
+const heartbeat = 15 * time.Second
+
+// streamEvents struct handle requirements to stream events
+type streamEvents struct {
+	ProjectID int `form:"projectId" json:"projectId"`
+}
+
+// Serve send events matching the filter provided as server-sent events
+// until the client goes away or the api shuts down
+func Serve(c *gin.Context, filter func(Event) bool) {
+	events, unsubscribe := Subscribe(filter)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case e := <-events:
+			c.SSEvent(e.Type, e)
+		case <-ticker.C:
+			_, err := c.Writer.WriteString(": heartbeat\n\n")
+			if err != nil {
+				return
+			}
+		case <-c.Request.Context().Done():
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// Stream permit to follow changes of all runs and executions as server-sent events,
+// only the ones of the project provided when projectId is set
+func Stream(c *gin.Context) {
+	var (
+		p streamEvents
+	)
+	if err := c.ShouldBind(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	Serve(c, func(e Event) bool {
+		return p.ProjectID == 0 || e.ProjectID == p.ProjectID
+	})
+}
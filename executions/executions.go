@@ -3,14 +3,18 @@ package executions
 
 import (
 	"encoding/hex"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/Lord-Y/cypress-parallel-api/commons"
+	"github.com/Lord-Y/cypress-parallel-api/events"
+	"github.com/Lord-Y/cypress-parallel-api/hooks"
 	"github.com/Lord-Y/cypress-parallel-api/kubernetes"
 	"github.com/Lord-Y/cypress-parallel-api/tools"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 // listExecutions struct handle requirements to get executions
@@ -51,6 +55,11 @@ type uniqIDExecutions struct {
 	UniqID string `form:"uniqId" json:"uniqId" binding:"required"`
 }
 
+// cancelExecutions struct handle requirements to cancel uniq id executions
+type cancelExecutions struct {
+	UniqID string `form:"uniqId" json:"uniqId" binding:"required"`
+}
+
 // List permit to retrieve executions with pagination
 func List(c *gin.Context) {
 	var (
@@ -128,13 +137,23 @@ func UpdateResultExecution(c *gin.Context) {
 		p.Result = string(decoded)
 	}
 
-	_, err := p.updateResult()
+	projectID, err := p.updateResult()
 	if err != nil {
 		log.Error().Err(err).Msg("Error occured while performing db query")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
 	} else {
 		c.JSON(http.StatusOK, "OK")
 	}
+	if projectID != 0 {
+		events.Publish(events.Event{
+			Type:      "result",
+			UniqID:    p.UniqID,
+			ProjectID: projectID,
+			Spec:      p.Spec,
+			Status:    p.ExecutionStatus,
+			Reason:    p.ExecutionErrorOutput,
+		})
+	}
 	log.Debug().Msgf("POST body %+v", p)
 
 	remaining, err := p.countExecutions()
@@ -151,11 +170,18 @@ func UpdateResultExecution(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
 		}
 		if len(pod) > 0 {
-			clientset, err := kubernetes.Client()
+			clientset, err := kubernetes.ClientFrom(c)
 			if err != nil {
 				log.Error().Err(err).Msg("Error occured while initializing kubernetes client")
 				return
 			}
+			if pod["job_name"] != "" {
+				err = kubernetes.DeleteJob(clientset, commons.GetKubernetesJobsNamespace(), pod["job_name"])
+				if err != nil {
+					log.Error().Err(err).Msgf("Error occured while trying to delete job name: %s", pod["job_name"])
+				}
+				return
+			}
 			err = kubernetes.DeletePod(clientset, commons.GetKubernetesJobsNamespace(), pod["pod_name"])
 			if err != nil {
 				log.Error().Err(err).Msgf("Error occured while trying to delete pod name: %s", pod["pod_name"])
@@ -215,3 +241,118 @@ func UniqID(c *gin.Context) {
 		c.JSON(http.StatusOK, result)
 	}
 }
+
+// Cancel permit to cancel the run of uniq id when it is not launched yet,
+// all its executions that are not finished yet and to delete all pods used by them
+func Cancel(c *gin.Context) {
+	var (
+		p cancelExecutions
+	)
+	id := c.Params.ByName("uniqId")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uniqId is missing in uri"})
+		return
+	}
+	p.UniqID = id
+
+	// the run must be cancelled first so launch workers won't create new executions and pods
+	projectID, runCancelled, err := p.cancelRun()
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing update db query")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	if runCancelled {
+		events.Publish(events.Event{
+			Type:      "run",
+			UniqID:    id,
+			ProjectID: projectID,
+			Status:    "CANCELLED",
+			Reason:    "Cancelled",
+		})
+	}
+
+	pods, err := p.podNames()
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	if len(pods) == 0 {
+		if runCancelled {
+			c.JSON(http.StatusOK, "OK")
+			return
+		}
+		c.AbortWithStatus(404)
+		return
+	}
+
+	// executions must be cancelled before deleting pods so the queue won't start new ones
+	cancelled, err := p.cancel()
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing update db query")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	for _, e := range cancelled {
+		events.Publish(e)
+	}
+
+	clientset, err := kubernetes.ClientFrom(c)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while initializing kubernetes client")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	for _, pod := range pods {
+		jobName := fmt.Sprintf("%s", pod["job_name"])
+		if jobName != "" {
+			err = kubernetes.DeleteJob(clientset, commons.GetKubernetesJobsNamespace(), jobName)
+			if err != nil && !k8serrors.IsNotFound(err) {
+				log.Error().Err(err).Msgf("Error occured while trying to delete job name: %s", jobName)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+				return
+			}
+			continue
+		}
+		podName := fmt.Sprintf("%s", pod["pod_name"])
+		if podName == "" {
+			continue
+		}
+		err = kubernetes.DeletePod(clientset, commons.GetKubernetesJobsNamespace(), podName)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			log.Error().Err(err).Msgf("Error occured while trying to delete pod name: %s", podName)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, "OK")
+}
+
+// Retry permit to launch a new run with failed specs of uniq id
+func Retry(c *gin.Context) {
+	id := c.Params.ByName("uniqId")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uniqId is missing in uri"})
+		return
+	}
+
+	clientset, err := kubernetes.ClientFrom(c)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while initializing kubernetes client")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	uniqID, statusCode, err := hooks.Retry(clientset, id)
+	if err != nil {
+		switch statusCode {
+		case http.StatusNotFound, http.StatusBadRequest:
+			c.JSON(statusCode, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"uniqId": uniqID, "parentUniqId": id})
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 
 	"github.com/Lord-Y/cypress-parallel-api/commons"
+	"github.com/Lord-Y/cypress-parallel-api/events"
 	_ "github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 	"github.com/syyongx/php2go"
@@ -158,8 +159,9 @@ func (p *readExecutions) read() (z []interface{}, err error) {
 	return finalRows, nil
 }
 
-// updateResult will update execution result in DB
-func (p *updateResultExecution) updateResult() (z string, err error) {
+// updateResult will update execution result in DB and return the project id of the execution,
+// 0 when it was not updated
+func (p *updateResultExecution) updateResult() (z int, err error) {
 	db, err := sql.Open(
 		"postgres",
 		commons.BuildDSN(),
@@ -170,7 +172,7 @@ func (p *updateResultExecution) updateResult() (z string, err error) {
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare("UPDATE executions SET result = $1, execution_status = $2, execution_error_output = $3, pod_cleaned = 'true' WHERE uniq_id = $4 AND spec = $5 AND branch = $6 RETURNING pod_name")
+	stmt, err := db.Prepare("UPDATE executions SET result = $1, execution_status = $2, execution_error_output = $3, pod_cleaned = 'true', finished_at = CURRENT_TIMESTAMP WHERE uniq_id = $4 AND spec = $5 AND branch = $6 AND execution_status <> 'CANCELLED' RETURNING project_id")
 	if err != nil && err != sql.ErrNoRows {
 		return z, err
 	}
@@ -201,7 +203,7 @@ func (p *updateResultExecution) countExecutions() (z map[string]string, err erro
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare("SELECT pod_name, execution_status FROM executions WHERE uniq_id = $1 AND execution_status = 'RUNNING' AND pod_name = (SELECT pod_name FROM executions WHERE uniq_id = $1 AND spec = $2)")
+	stmt, err := db.Prepare("SELECT pod_name, job_name, execution_status FROM executions WHERE uniq_id = $1 AND execution_status = 'RUNNING' AND (COALESCE(pod_name, ''), COALESCE(job_name, '')) = (SELECT COALESCE(pod_name, ''), COALESCE(job_name, '') FROM executions WHERE uniq_id = $1 AND spec = $2)")
 	if err != nil && err != sql.ErrNoRows {
 		return z, err
 	}
@@ -260,7 +262,7 @@ func (p *updateResultExecution) countExecutionsInverted() (z map[string]string,
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare("SELECT pod_name, execution_status FROM executions WHERE uniq_id = $1 AND spec = $2")
+	stmt, err := db.Prepare("SELECT pod_name, job_name, execution_status FROM executions WHERE uniq_id = $1 AND spec = $2")
 	if err != nil && err != sql.ErrNoRows {
 		return z, err
 	}
@@ -363,6 +365,62 @@ func GetExecutionIDForUnitTesting() (z map[string]string, err error) {
 	return m, nil
 }
 
+// GetLatestExecutionForUnitTesting in only for unit testing purpose and will return the latest execution created
+func GetLatestExecutionForUnitTesting() (z map[string]string, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to connect to DB")
+		return z, err
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("SELECT * FROM executions ORDER BY execution_id DESC LIMIT 1")
+	if err != nil && err != sql.ErrNoRows {
+		return z, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil && err != sql.ErrNoRows {
+		return z, err
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return z, err
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	m := make(map[string]string)
+	for rows.Next() {
+		err = rows.Scan(scanArgs...)
+		if err != nil {
+			return
+		}
+		var value string
+		for i, col := range values {
+			if col == nil {
+				value = ""
+			} else {
+				value = php2go.Stripslashes(string(col))
+			}
+			m[columns[i]] = value
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return z, err
+	}
+	return m, nil
+}
+
 // search will return all projects
 func (p *searchExecutions) search() (z []interface{}, err error) {
 	db, err := sql.Open(
@@ -540,3 +598,131 @@ func (p *uniqIDExecutions) uniqId() (z []interface{}, err error) {
 	}
 	return finalRows, nil
 }
+
+// podNames will return pod names of all executions of the uniq id provided
+func (p *cancelExecutions) podNames() (z []map[string]interface{}, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("SELECT DISTINCT COALESCE(pod_name, '') pod_name, COALESCE(job_name, '') job_name FROM executions WHERE uniq_id = $1")
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(
+		php2go.Addslashes(p.UniqID),
+	)
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	m := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		err = rows.Scan(scanArgs...)
+		if err != nil {
+			return
+		}
+		var value string
+		sub := make(map[string]interface{})
+		for i, col := range values {
+			if col == nil {
+				value = ""
+			} else {
+				value = php2go.Stripslashes(string(col))
+			}
+			sub[columns[i]] = value
+		}
+		m = append(m, sub)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return m, nil
+}
+
+// cancelRun will mark the run of the uniq id as CANCELLED when it is still PENDING or LAUNCHING
+// so launch workers stop creating its executions and pods. cancelled is false otherwise
+func (p *cancelExecutions) cancelRun() (projectID int, cancelled bool, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to connect to DB")
+		return
+	}
+	defer db.Close()
+
+	err = db.QueryRow(
+		"UPDATE runs SET run_status = 'CANCELLED', reason = 'Cancelled', finished_at = CURRENT_TIMESTAMP WHERE uniq_id = $1 AND run_status IN ('PENDING', 'LAUNCHING') RETURNING project_id",
+		php2go.Addslashes(p.UniqID),
+	).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		return projectID, false, nil
+	}
+	if err != nil {
+		return
+	}
+	return projectID, true, nil
+}
+
+// cancel will cancel all executions of the uniq id that are not finished yet and return their events
+func (p *cancelExecutions) cancel() (z []events.Event, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to connect to DB")
+		return z, err
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("UPDATE executions SET execution_status = 'CANCELLED', execution_error_output = 'Cancelled' WHERE uniq_id = $1 AND execution_status IN ('NOT_STARTED', 'QUEUED', 'RUNNING') RETURNING project_id, spec, COALESCE(pod_name, ''), COALESCE(job_name, '')")
+	if err != nil && err != sql.ErrNoRows {
+		return z, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query(
+		php2go.Addslashes(p.UniqID),
+	)
+	if err != nil {
+		return z, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := events.Event{
+			Type:   "execution",
+			UniqID: p.UniqID,
+			Status: "CANCELLED",
+			Reason: "Cancelled",
+		}
+		err = rows.Scan(&e.ProjectID, &e.Spec, &e.PodName, &e.JobName)
+		if err != nil {
+			return z, err
+		}
+		for _, value := range []*string{&e.Spec, &e.PodName, &e.JobName} {
+			*value = php2go.Stripslashes(*value)
+		}
+		z = append(z, e)
+	}
+	return z, rows.Err()
+}
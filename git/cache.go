@@ -0,0 +1,361 @@
+// Package git will manage all requirements to clone repository
+package git
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Lord-Y/cypress-parallel-api/commons"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/rs/zerolog/log"
+)
+
+// mirrorLock serialize fetches, checkouts and eviction of a mirror
+// and count its users so it won't be evicted while in use
+type mirrorLock struct {
+	sync.Mutex
+	users    int
+	evicting bool
+}
+
+var (
+	mirrorsMutex sync.Mutex
+	mirrors      = make(map[string]*mirrorLock)
+	// mirrorRefSpecs are the references fetched in full mirrors.
+	// Tags are fetched too so runs can checkout them
+	mirrorRefSpecs = []config.RefSpec{
+		"+refs/heads/*:refs/heads/*",
+		"+refs/tags/*:refs/tags/*",
+	}
+)
+
+// shallowDepth is the number of commits fetched per branch in shallow mirrors
+const shallowDepth = 1
+
+// lockMirror lock the mirror of the path provided and return the function to unlock it
+func lockMirror(path string) (unlock func()) {
+	mirrorsMutex.Lock()
+	m, ok := mirrors[path]
+	if !ok {
+		m = &mirrorLock{}
+		mirrors[path] = m
+	}
+	if m.evicting {
+		log.Debug().Msgf("Waiting for eviction of git mirror %s", path)
+	}
+	m.users++
+	mirrorsMutex.Unlock()
+
+	m.Lock()
+	return func() {
+		m.Unlock()
+		mirrorsMutex.Lock()
+		m.users--
+		if m.users == 0 {
+			delete(mirrors, path)
+		}
+		mirrorsMutex.Unlock()
+	}
+}
+
+// lockUnusedMirror lock the mirror of the path provided for its eviction
+// and return the function to unlock it, or false when the mirror is in use.
+// Users coming meanwhile wait for the eviction to be done and start from an empty mirror
+func lockUnusedMirror(path string) (unlock func(), ok bool) {
+	mirrorsMutex.Lock()
+	if _, inUse := mirrors[path]; inUse {
+		mirrorsMutex.Unlock()
+		return nil, false
+	}
+	m := &mirrorLock{users: 1, evicting: true}
+	mirrors[path] = m
+	m.Lock()
+	mirrorsMutex.Unlock()
+
+	return func() {
+		mirrorsMutex.Lock()
+		m.evicting = false
+		m.users--
+		if m.users == 0 {
+			delete(mirrors, path)
+		}
+		mirrorsMutex.Unlock()
+		m.Unlock()
+	}, true
+}
+
+// mirrorPath return the path of the bare mirror of the repository provided
+func mirrorPath(repository string) string {
+	return filepath.Join(commons.GetGitCacheDir(), fmt.Sprintf("%x.git", sha256.Sum256([]byte(repository))))
+}
+
+// initMirror remove what is left at the path provided and init an empty bare mirror of the repository
+func (c *Repository) initMirror(path string) (repo *git.Repository, err error) {
+	os.RemoveAll(path)
+	repo, err = git.PlainInit(path, true)
+	if err != nil {
+		return
+	}
+	_, err = repo.CreateRemote(&config.RemoteConfig{
+		Name:  git.DefaultRemoteName,
+		URLs:  []string{c.Repository},
+		Fetch: mirrorRefSpecs,
+	})
+	if err != nil {
+		os.RemoveAll(path)
+	}
+	return
+}
+
+// mirror create the bare mirror of the repository if it does not exist yet and fetch the branch provided,
+// or the default one when empty. It return the mirror and the reference of the branch fetched.
+// Mirrors only fetch the last commit of the branches used so big repositories are quickly available.
+// Once a commit or a tag is requested, the mirror is fetched again with the whole history of all branches and tags
+// as they can't be resolved otherwise, and is kept that way as following fetches are incremental
+func (c *Repository) mirror(path string, auth transport.AuthMethod, branch plumbing.ReferenceName) (repo *git.Repository, z plumbing.ReferenceName, statusCode int, err error) {
+	fresh := false
+	repo, err = git.PlainOpen(path)
+	if err != nil {
+		// missing or broken mirror, let's start from scratch
+		repo, err = c.initMirror(path)
+		if err != nil {
+			return nil, z, 500, err
+		}
+		fresh = true
+	}
+	shallows, err := repo.Storer.Shallow()
+	if err != nil {
+		return nil, z, 500, err
+	}
+	shallow := fresh || len(shallows) > 0
+	if shallow && c.Revision != "" && !fresh {
+		log.Debug().Msgf("Fetching whole history of shallow git mirror %s to resolve %s", path, c.Revision)
+		repo, err = c.initMirror(path)
+		if err != nil {
+			return nil, z, 500, err
+		}
+	}
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return nil, z, 500, err
+	}
+
+	// listing remote references also make sure the credentials provided
+	// can access the repository before using what is already cached
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return nil, z, 400, err
+	}
+	z = branch
+	if z == "" {
+		z = defaultBranch(refs)
+		if z == "" {
+			return nil, z, 400, fmt.Errorf("Default branch of repository %s not found", c.Repository)
+		}
+	}
+
+	options := &git.FetchOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   mirrorRefSpecs,
+		Auth:       auth,
+		Force:      true,
+		Tags:       git.NoTags,
+	}
+	if shallow && c.Revision == "" {
+		if !hasReference(refs, z) {
+			return nil, z, 400, fmt.Errorf("Branch %s not found", z.Short())
+		}
+		options.RefSpecs = []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", z, z))}
+		options.Depth = shallowDepth
+	}
+	err = remote.Fetch(options)
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, z, 400, err
+	}
+	return repo, z, 200, nil
+}
+
+// hasReference return true when the reference provided is in the references provided
+func hasReference(refs []*plumbing.Reference, name plumbing.ReferenceName) bool {
+	for _, ref := range refs {
+		if ref.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultBranch return the branch pointed by HEAD in the references provided
+func defaultBranch(refs []*plumbing.Reference) plumbing.ReferenceName {
+	var head *plumbing.Reference
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD {
+			head = ref
+		}
+	}
+	if head == nil {
+		return ""
+	}
+	if head.Type() == plumbing.SymbolicReference {
+		return head.Target()
+	}
+	for _, ref := range refs {
+		if ref.Name().IsBranch() && ref.Hash() == head.Hash() {
+			return ref.Name()
+		}
+	}
+	return ""
+}
+
+// resolveCommit return the hash of the commit of the revision provided,
+// a commit SHA abbreviated or not or a tag, or of the last commit of the branch provided when revision is empty
+func resolveCommit(repo *git.Repository, branch plumbing.ReferenceName, revision string) (hash plumbing.Hash, statusCode int, err error) {
+	if revision != "" {
+		h, err := repo.ResolveRevision(plumbing.Revision(revision))
+		if err != nil {
+			return hash, 400, fmt.Errorf("Commit or tag %s not found: %s", revision, err.Error())
+		}
+		return *h, 200, nil
+	}
+	ref, err := repo.Reference(branch, true)
+	if err != nil {
+		return hash, 400, fmt.Errorf("Branch %s not found: %s", branch.Short(), err.Error())
+	}
+	return ref.Hash(), 200, nil
+}
+
+// checkout write files of the commit provided into the directory provided.
+// Only regular files are written, symlinks and submodules are skipped
+func checkout(repo *git.Repository, hash plumbing.Hash, dir string) (statusCode int, err error) {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return 500, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return 500, err
+	}
+
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if f.Mode != filemode.Regular && f.Mode != filemode.Executable && f.Mode != filemode.Deprecated {
+			return nil
+		}
+		path := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("Invalid file path %s", f.Name)
+		}
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return err
+		}
+		mode, err := f.Mode.ToOSFileMode()
+		if err != nil {
+			return err
+		}
+		reader, err := f.Reader()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(file, reader)
+		return err
+	})
+	if err != nil {
+		return 500, err
+	}
+	return 200, nil
+}
+
+// cachedMirror hold a mirror of the cache and its usage
+type cachedMirror struct {
+	path     string
+	size     int64
+	lastUsed time.Time
+}
+
+// dirSize return the size in bytes of all files of the directory provided
+func dirSize(path string) (z int64, err error) {
+	err = filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			z += info.Size()
+		}
+		return nil
+	})
+	return
+}
+
+// Evict permit to remove mirrors of the cache not used since the max age
+// and then the least recently used ones until the cache size is below the max size.
+// Mirrors in use are never removed and the cache lock is not held while removing the others
+func Evict() (err error) {
+	entries, err := os.ReadDir(commons.GetGitCacheDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return
+	}
+
+	var (
+		cached []cachedMirror
+		total  int64
+	)
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasSuffix(entry.Name(), ".git") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(commons.GetGitCacheDir(), entry.Name())
+		size, err := dirSize(path)
+		if err != nil {
+			return err
+		}
+		cached = append(cached, cachedMirror{path: path, size: size, lastUsed: info.ModTime()})
+		total += size
+	}
+	sort.Slice(cached, func(i, j int) bool {
+		return cached[i].lastUsed.Before(cached[j].lastUsed)
+	})
+
+	maxSize := commons.GetGitCacheMaxSize() * 1024 * 1024
+	for _, m := range cached {
+		if time.Since(m.lastUsed) < commons.GetGitCacheMaxAge() && total <= maxSize {
+			continue
+		}
+		unlock, ok := lockUnusedMirror(m.path)
+		if !ok {
+			continue
+		}
+		log.Debug().Msgf("Evicting git mirror %s of %d bytes last used at %s", m.path, m.size, m.lastUsed)
+		err = os.RemoveAll(m.path)
+		unlock()
+		if err != nil {
+			return
+		}
+		total -= m.size
+	}
+	return
+}
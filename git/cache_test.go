@@ -0,0 +1,335 @@
+// Package git will manage all requirements to clone repository
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSourceRepository init a local repository with a spec file on master branch
+// and return its path and worktree
+func newSourceRepository(t *testing.T) (string, *git.Worktree) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, dir, w, "cypress/integration/first.spec.js")
+	return dir, w
+}
+
+// commitFile write and commit the file provided in the source repository
+func commitFile(t *testing.T, dir string, w *git.Worktree, name string) {
+	err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, name), []byte("describe('spec', () => {})"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Add(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Commit(name, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClone_cache(t *testing.T) {
+	assert := assert.New(t)
+	os.Setenv("CYPRESS_PARALLEL_API_GIT_CACHE_DIR", t.TempDir())
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_GIT_CACHE_DIR")
+
+	source, w := newSourceRepository(t)
+	c := &Repository{Repository: source}
+
+	z, commit, _, err := c.Clone()
+	defer os.RemoveAll(z)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.FileExists(filepath.Join(z, "cypress/integration/first.spec.js"))
+	assert.DirExists(mirrorPath(source))
+	repo, err := git.PlainOpen(source)
+	if !assert.NoError(err) {
+		return
+	}
+	head, err := repo.Head()
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(head.Hash().String(), commit)
+
+	_, err = os.Stat(filepath.Join(z, ".git"))
+	assert.True(os.IsNotExist(err))
+
+	// new commits are fetched in the existing mirror
+	commitFile(t, source, w, "cypress/integration/second.spec.js")
+	z, _, _, err = c.Clone()
+	defer os.RemoveAll(z)
+	assert.NoError(err)
+	assert.FileExists(filepath.Join(z, "cypress/integration/second.spec.js"))
+
+	err = w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true})
+	if !assert.NoError(err) {
+		return
+	}
+	commitFile(t, source, w, "cypress/integration/feature.spec.js")
+
+	c.Branch = "feature"
+	z, _, _, err = c.Clone()
+	defer os.RemoveAll(z)
+	assert.NoError(err)
+	assert.FileExists(filepath.Join(z, "cypress/integration/feature.spec.js"))
+
+	// master is the default branch of the repository
+	err = w.Checkout(&git.CheckoutOptions{Branch: plumbing.Master})
+	if !assert.NoError(err) {
+		return
+	}
+	c.Branch = "master"
+	z, _, _, err = c.Clone()
+	defer os.RemoveAll(z)
+	assert.NoError(err)
+	assert.NoFileExists(filepath.Join(z, "cypress/integration/feature.spec.js"))
+
+	c.Branch = "fake"
+	z, _, statusCode, err := c.Clone()
+	defer os.RemoveAll(z)
+	assert.Error(err)
+	assert.Equal(400, statusCode)
+}
+
+func TestClone_revision(t *testing.T) {
+	assert := assert.New(t)
+	os.Setenv("CYPRESS_PARALLEL_API_GIT_CACHE_DIR", t.TempDir())
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_GIT_CACHE_DIR")
+
+	source, w := newSourceRepository(t)
+	repo, err := git.PlainOpen(source)
+	if !assert.NoError(err) {
+		return
+	}
+	first, err := repo.Head()
+	if !assert.NoError(err) {
+		return
+	}
+	_, err = repo.CreateTag("v1.0.0", first.Hash(), nil)
+	if !assert.NoError(err) {
+		return
+	}
+	_, err = repo.CreateTag("v1.0.1", first.Hash(), &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		Message: "v1.0.1",
+	})
+	if !assert.NoError(err) {
+		return
+	}
+	commitFile(t, source, w, "cypress/integration/second.spec.js")
+
+	// revisions take precedence over the branch head
+	for _, revision := range []string{first.Hash().String(), first.Hash().String()[0:7], "v1.0.0", "v1.0.1"} {
+		c := &Repository{Repository: source, Revision: revision}
+		z, commit, _, err := c.Clone()
+		defer os.RemoveAll(z)
+		if !assert.NoError(err, revision) {
+			continue
+		}
+		assert.Equal(first.Hash().String(), commit, revision)
+		assert.FileExists(filepath.Join(z, "cypress/integration/first.spec.js"))
+		assert.NoFileExists(filepath.Join(z, "cypress/integration/second.spec.js"))
+	}
+
+	c := &Repository{Repository: source, Revision: "fake"}
+	z, _, statusCode, err := c.Clone()
+	defer os.RemoveAll(z)
+	assert.Error(err)
+	assert.Equal(400, statusCode)
+}
+
+func TestClone_shallow(t *testing.T) {
+	assert := assert.New(t)
+	os.Setenv("CYPRESS_PARALLEL_API_GIT_CACHE_DIR", t.TempDir())
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_GIT_CACHE_DIR")
+
+	source, w := newSourceRepository(t)
+	repo, err := git.PlainOpen(source)
+	if !assert.NoError(err) {
+		return
+	}
+	first, err := repo.Head()
+	if !assert.NoError(err) {
+		return
+	}
+	commitFile(t, source, w, "cypress/integration/second.spec.js")
+
+	// only the last commit of the branch is fetched
+	c := &Repository{Repository: source}
+	z, _, _, err := c.Clone()
+	defer os.RemoveAll(z)
+	if !assert.NoError(err) {
+		return
+	}
+	mirror, err := git.PlainOpen(mirrorPath(source))
+	if !assert.NoError(err) {
+		return
+	}
+	shallows, err := mirror.Storer.Shallow()
+	assert.NoError(err)
+	assert.NotEmpty(shallows)
+	_, err = mirror.CommitObject(first.Hash())
+	assert.Error(err)
+
+	// the whole history is fetched once a commit is requested and kept afterwards
+	for _, revision := range []string{first.Hash().String()[0:7], ""} {
+		c.Revision = revision
+		z, _, _, err = c.Clone()
+		defer os.RemoveAll(z)
+		if !assert.NoError(err, revision) {
+			return
+		}
+		mirror, err = git.PlainOpen(mirrorPath(source))
+		if !assert.NoError(err) {
+			return
+		}
+		shallows, err = mirror.Storer.Shallow()
+		assert.NoError(err)
+		assert.Empty(shallows, revision)
+		_, err = mirror.CommitObject(first.Hash())
+		assert.NoError(err, revision)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	assert := assert.New(t)
+	os.Setenv("CYPRESS_PARALLEL_API_GIT_CACHE_DIR", t.TempDir())
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_GIT_CACHE_DIR")
+
+	first, _ := newSourceRepository(t)
+	second, _ := newSourceRepository(t)
+	for _, source := range []string{first, second} {
+		c := &Repository{Repository: source}
+		z, _, _, err := c.Clone()
+		os.RemoveAll(z)
+		if !assert.NoError(err) {
+			return
+		}
+	}
+
+	// first mirror not used for a while must be evicted
+	old := time.Now().Add(-48 * time.Hour)
+	assert.NoError(os.Chtimes(mirrorPath(first), old, old))
+	os.Setenv("CYPRESS_PARALLEL_API_GIT_CACHE_MAX_AGE", "24h")
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_GIT_CACHE_MAX_AGE")
+	assert.NoError(Evict())
+	assert.NoDirExists(mirrorPath(first))
+	assert.DirExists(mirrorPath(second))
+
+	// mirrors in use are kept even when the cache is too big
+	os.Setenv("CYPRESS_PARALLEL_API_GIT_CACHE_MAX_SIZE", "1")
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_GIT_CACHE_MAX_SIZE")
+	assert.NoError(os.WriteFile(filepath.Join(mirrorPath(second), "big"), make([]byte, 2*1024*1024), 0644))
+	unlock := lockMirror(mirrorPath(second))
+	assert.NoError(Evict())
+	assert.DirExists(mirrorPath(second))
+	unlock()
+
+	assert.NoError(Evict())
+	assert.NoDirExists(mirrorPath(second))
+}
+
+func TestLockMirror(t *testing.T) {
+	assert := assert.New(t)
+
+	unlock := lockMirror("mirror")
+	locked := make(chan bool)
+	go func() {
+		defer lockMirror("mirror")()
+		locked <- true
+	}()
+
+	select {
+	case <-locked:
+		assert.Fail("mirror must be locked")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+
+	mirrorsMutex.Lock()
+	defer mirrorsMutex.Unlock()
+	assert.Empty(mirrors)
+}
+
+func TestLockUnusedMirror(t *testing.T) {
+	assert := assert.New(t)
+
+	unlock := lockMirror("mirror")
+	_, ok := lockUnusedMirror("mirror")
+	assert.False(ok)
+	unlock()
+
+	unlockEviction, ok := lockUnusedMirror("mirror")
+	if !assert.True(ok) {
+		return
+	}
+	locked := make(chan bool)
+	go func() {
+		defer lockMirror("mirror")()
+		locked <- true
+	}()
+
+	select {
+	case <-locked:
+		assert.Fail("mirror must be locked while evicted")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlockEviction()
+	<-locked
+
+	mirrorsMutex.Lock()
+	defer mirrorsMutex.Unlock()
+	assert.Empty(mirrors)
+}
+
+func TestBranches(t *testing.T) {
+	assert := assert.New(t)
+
+	source, w := newSourceRepository(t)
+	err := w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true})
+	if !assert.NoError(err) {
+		return
+	}
+	err = w.Checkout(&git.CheckoutOptions{Branch: plumbing.Master})
+	if !assert.NoError(err) {
+		return
+	}
+
+	c := &Repository{Repository: source}
+	z, head, statusCode, err := c.Branches()
+	assert.NoError(err)
+	assert.Equal(200, statusCode)
+	assert.Equal([]string{"feature", "master"}, z)
+	assert.Equal("master", head)
+
+	c.Repository = filepath.Join(t.TempDir(), "fake")
+	_, _, statusCode, err = c.Branches()
+	assert.Error(err)
+	assert.Equal(400, statusCode)
+}
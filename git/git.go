@@ -2,27 +2,122 @@
 package git
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/Lord-Y/cypress-parallel-api/commons"
 	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/icrowley/fake"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 type Repository struct {
-	Repository string // HTTP(s) git repository
-	Username   string // Username to use to fetch repository if required
-	Password   string // Password to use to fetch repository if required
-	Branch     string // Branch in which specs are hold
+	Repository    string // HTTP(s) or SSH git repository
+	Username      string // Username to use to fetch repository if required
+	Password      string // Password to use to fetch repository if required
+	Branch        string // Branch in which specs are hold
+	SSHPrivateKey string // PEM encoded SSH private key to use to fetch repository over SSH if required
+	SSHKnownHosts string // known_hosts content used to verify SSH host keys, user and system known_hosts files are used when empty
+	Revision      string // Commit SHA, abbreviated or not, or tag to checkout instead of the head of Branch
 }
 
-// Clone permit to clone git repository
-func (c *Repository) Clone() (z string, statusCode int, err error) {
+// ValidateSSHPrivateKey return an error when the PEM encoded SSH private key provided
+// can't be parsed or is protected by a passphrase
+func ValidateSSHPrivateKey(key string) (err error) {
+	if key == "" {
+		return
+	}
+	_, err = ssh.ParsePrivateKey([]byte(key))
+	if err != nil {
+		return fmt.Errorf("SSH private key is invalid: %s", err.Error())
+	}
+	return
+}
+
+// ValidateSSHKnownHosts return an error when one of the known_hosts lines provided can't be parsed
+func ValidateSSHKnownHosts(hosts string) (err error) {
+	rest := []byte(hosts)
+	for len(bytes.TrimSpace(rest)) > 0 {
+		_, _, _, _, rest, err = ssh.ParseKnownHosts(rest)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("SSH known hosts are invalid: %s", err.Error())
+		}
+	}
+	return
+}
+
+// hostKeyCallback return the callback verifying SSH host keys against known hosts of the repository
+// or against user and system known_hosts files when not set
+func (c *Repository) hostKeyCallback() (z ssh.HostKeyCallback, err error) {
+	if c.SSHKnownHosts == "" {
+		return gitssh.NewKnownHostsCallback()
+	}
+	file, err := os.CreateTemp(os.TempDir(), "known_hosts")
+	if err != nil {
+		return
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(c.SSHKnownHosts)
+	file.Close()
+	if err != nil {
+		return
+	}
+	return knownhosts.New(file.Name())
+}
+
+// auth return the authentication method to use to fetch repository
+// or nil when it must be fetched anonymously
+func (c *Repository) auth() (z transport.AuthMethod, err error) {
+	if c.SSHPrivateKey != "" {
+		endpoint, err := transport.NewEndpoint(c.Repository)
+		if err != nil {
+			return nil, err
+		}
+		user := endpoint.User
+		if user == "" {
+			user = "git"
+		}
+		keys, err := gitssh.NewPublicKeys(user, []byte(c.SSHPrivateKey), "")
+		if err != nil {
+			return nil, err
+		}
+		keys.HostKeyCallback, err = c.hostKeyCallback()
+		if err != nil {
+			return nil, err
+		}
+		return keys, nil
+	}
+	if c.Username != "" {
+		return &http.BasicAuth{
+			Username: c.Username,
+			Password: c.Password,
+		}, nil
+	}
+	return nil, nil
+}
+
+// Clone permit to checkout the branch, or the revision when set, of the git repository into a temporary directory
+// and return the SHA of the commit checked out.
+// The repository is mirrored in the cache and only fetched incrementally on next clones
+func (c *Repository) Clone() (z string, commit string, statusCode int, err error) {
 	var (
 		targetBranch string
-		result       *git.Repository
 	)
 
 	if c.Branch != "" {
@@ -38,56 +133,71 @@ func (c *Repository) Clone() (z string, statusCode int, err error) {
 
 	z, err = os.MkdirTemp(os.TempDir(), fake.CharactersN(10))
 	if err != nil {
-		return z, 500, err
+		return z, commit, 500, err
 	}
 
+	auth, err := c.auth()
+	if err != nil {
+		log.Debug().Msgf("Authentication error %s", err.Error())
+		return z, commit, 400, err
+	}
+
+	err = os.MkdirAll(commons.GetGitCacheDir(), 0700)
+	if err != nil {
+		return z, commit, 500, err
+	}
+	path := mirrorPath(c.Repository)
+	unlock := lockMirror(path)
+	var branch plumbing.ReferenceName
 	if targetBranch != "" {
-		if c.Username != "" {
-			result, err = git.PlainClone(z, false, &git.CloneOptions{
-				URL: c.Repository,
-				Auth: &http.BasicAuth{
-					Username: c.Username,
-					Password: c.Password,
-				},
-			})
-		} else {
-			result, err = git.PlainClone(z, false, &git.CloneOptions{
-				URL: c.Repository,
-			})
-		}
-		if err != nil {
-			log.Debug().Msgf("Cloning repo error %s", err.Error())
-			return z, 400, err
+		branch = plumbing.NewBranchReferenceName(targetBranch)
+		if strings.HasPrefix(targetBranch, "refs/") {
+			branch = plumbing.ReferenceName(targetBranch)
 		}
-		w, err := result.Worktree()
-		if err != nil {
-			log.Debug().Msgf("Tree error %s", err.Error())
-			return z, 500, err
-		}
-		err = w.Checkout(&git.CheckoutOptions{
-			Branch: plumbing.ReferenceName(targetBranch),
-		})
-		if err != nil {
-			log.Debug().Msgf("Checkout %s", err.Error())
-			return z, 400, err
-		}
-	} else {
-		if c.Username != "" {
-			_, err = git.PlainClone(z, false, &git.CloneOptions{
-				URL: c.Repository,
-				Auth: &http.BasicAuth{
-					Username: c.Username,
-					Password: c.Password,
-				},
-			})
-		} else {
-			_, err = git.PlainClone(z, false, &git.CloneOptions{
-				URL: c.Repository,
-			})
-		}
-		if err != nil {
-			return z, 400, err
+	}
+	repo, branch, statusCode, err := c.mirror(path, auth, branch)
+	if err != nil {
+		unlock()
+		log.Debug().Msgf("Fetching repo error %s", err.Error())
+		return z, commit, statusCode, err
+	}
+
+	hash, statusCode, err := resolveCommit(repo, branch, c.Revision)
+	if err == nil {
+		statusCode, err = checkout(repo, hash, z)
+	}
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		log.Error().Err(err).Msgf("Error occured while updating last use of git mirror %s", path)
+	}
+	unlock()
+	if err != nil {
+		log.Debug().Msgf("Checkout %s", err.Error())
+		return z, commit, statusCode, err
+	}
+	return z, hash.String(), statusCode, nil
+}
+
+// Branches permit to list branches of the remote repository without fetching it.
+// Branches are sorted and returned alongside the default branch of the repository
+func (c *Repository) Branches() (z []string, head string, statusCode int, err error) {
+	auth, err := c.auth()
+	if err != nil {
+		return z, head, 400, err
+	}
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{c.Repository},
+	})
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return z, head, 400, err
+	}
+	for _, ref := range refs {
+		if ref.Name().IsBranch() {
+			z = append(z, ref.Name().Short())
 		}
 	}
-	return
+	sort.Strings(z)
+	return z, defaultBranch(refs).Short(), 200, nil
 }
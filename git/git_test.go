@@ -2,17 +2,26 @@
 package git
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
 	"os"
 	"testing"
 
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 func TestClone_fail(t *testing.T) {
 	assert := assert.New(t)
 	c := &Repository{}
 
-	_, _, err := c.Clone()
+	_, _, _, err := c.Clone()
 	assert.Error(err)
 }
 
@@ -23,7 +32,7 @@ func TestClone_fail_branch(t *testing.T) {
 	c.Repository = "https://github.com/cypress-io/cypress-example-kitchensink.git"
 	c.Branch = "test"
 
-	z, _, err := c.Clone()
+	z, _, _, err := c.Clone()
 	defer os.RemoveAll(z)
 	assert.Error(err)
 }
@@ -35,7 +44,7 @@ func TestClone_fail_user(t *testing.T) {
 	c.Repository = "https://github.com/cypress-io/cypress-example-kitchensink.git"
 	c.Username = "test"
 
-	z, _, err := c.Clone()
+	z, _, _, err := c.Clone()
 	defer os.RemoveAll(z)
 	assert.Error(err)
 }
@@ -48,7 +57,7 @@ func TestClone_fail_user_branch(t *testing.T) {
 	c.Username = "test"
 	c.Branch = "test"
 
-	z, _, err := c.Clone()
+	z, _, _, err := c.Clone()
 	defer os.RemoveAll(z)
 	assert.Error(err)
 }
@@ -59,7 +68,7 @@ func TestClone_success(t *testing.T) {
 
 	c.Repository = "https://github.com/cypress-io/cypress-example-kitchensink.git"
 
-	z, _, err := c.Clone()
+	z, _, _, err := c.Clone()
 	defer os.RemoveAll(z)
 	assert.Nil(err)
 }
@@ -71,7 +80,87 @@ func TestClone_success_master(t *testing.T) {
 	c.Repository = "https://github.com/cypress-io/cypress-example-kitchensink.git"
 	c.Branch = "master"
 
-	z, _, err := c.Clone()
+	z, _, _, err := c.Clone()
 	defer os.RemoveAll(z)
 	assert.Nil(err)
 }
+
+// newSSHKey return a PEM encoded SSH private key and its public key
+func newSSHKey(t *testing.T) (string, ssh.PublicKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})), pub
+}
+
+func TestValidateSSHPrivateKey(t *testing.T) {
+	assert := assert.New(t)
+	key, _ := newSSHKey(t)
+
+	assert.NoError(ValidateSSHPrivateKey(""))
+	assert.NoError(ValidateSSHPrivateKey(key))
+	assert.Error(ValidateSSHPrivateKey("fake"))
+}
+
+func TestValidateSSHKnownHosts(t *testing.T) {
+	assert := assert.New(t)
+	_, pub := newSSHKey(t)
+
+	assert.NoError(ValidateSSHKnownHosts(""))
+	assert.NoError(ValidateSSHKnownHosts(knownhosts.Line([]string{"github.com"}, pub) + "\n# comment\n"))
+	assert.Error(ValidateSSHKnownHosts("github.com fake"))
+}
+
+func TestRepository_auth(t *testing.T) {
+	assert := assert.New(t)
+	key, pub := newSSHKey(t)
+	_, other := newSSHKey(t)
+
+	c := &Repository{Repository: "https://github.com/cypress-io/cypress-example-kitchensink.git"}
+	auth, err := c.auth()
+	assert.NoError(err)
+	assert.Nil(auth)
+
+	c.Username = "test"
+	auth, err = c.auth()
+	assert.NoError(err)
+	assert.IsType(&http.BasicAuth{}, auth)
+
+	c.Repository = "deploy@github.com:cypress-io/cypress-example-kitchensink.git"
+	c.SSHPrivateKey = key
+	c.SSHKnownHosts = knownhosts.Line([]string{"github.com"}, pub)
+	auth, err = c.auth()
+	if !assert.NoError(err) {
+		return
+	}
+	keys := auth.(*gitssh.PublicKeys)
+	assert.Equal("deploy", keys.User)
+	remote := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 22}
+	assert.NoError(keys.HostKeyCallback("github.com:22", remote, pub))
+	assert.Error(keys.HostKeyCallback("github.com:22", remote, other))
+	assert.Error(keys.HostKeyCallback("gitlab.com:22", remote, pub))
+
+	c.SSHPrivateKey = "fake"
+	_, err = c.auth()
+	assert.Error(err)
+}
+
+func TestClone_fail_ssh(t *testing.T) {
+	assert := assert.New(t)
+	key, pub := newSSHKey(t)
+	c := &Repository{}
+
+	c.Repository = "git@github.com:cypress-io/cypress-example-kitchensink.git"
+	c.SSHPrivateKey = key
+	c.SSHKnownHosts = knownhosts.Line([]string{"github.com"}, pub)
+
+	z, _, statusCode, err := c.Clone()
+	defer os.RemoveAll(z)
+	assert.Error(err)
+	assert.Equal(400, statusCode)
+}
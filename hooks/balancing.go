@@ -0,0 +1,89 @@
+// Package hooks will manage all hooks requirements
+package hooks
+
+import (
+	"math"
+	"sort"
+)
+
+// estimate return the expected duration in seconds of each spec.
+// Specs without history are estimated from their file size with the seconds per byte
+// ratio of known specs, or from the average known duration when their size is unknown
+func estimate(specs []string, durations map[string]float64, sizes map[string]int64) (weights map[string]float64) {
+	var (
+		knownDurations, knownSizes float64
+		known                      int
+	)
+	weights = make(map[string]float64)
+
+	for _, spec := range specs {
+		if duration, ok := durations[spec]; ok && duration > 0 {
+			known++
+			knownDurations += duration
+			if size, ok := sizes[spec]; ok && size > 0 {
+				knownSizes += float64(size)
+			}
+		}
+	}
+
+	average := 1.0
+	if known > 0 {
+		average = knownDurations / float64(known)
+	}
+	ratio := 1.0
+	if knownSizes > 0 {
+		ratio = knownDurations / knownSizes
+	}
+
+	for _, spec := range specs {
+		if duration, ok := durations[spec]; ok && duration > 0 {
+			weights[spec] = duration
+		} else if size, ok := sizes[spec]; ok && size > 0 {
+			weights[spec] = float64(size) * ratio
+		} else {
+			weights[spec] = average
+		}
+	}
+	return
+}
+
+// balance distribute specs across the number of shards provided so every shard
+// runs roughly the same duration. Heaviest specs are assigned first to the lightest shard
+func balance(specs []string, weights map[string]float64, count int) (shards [][]string) {
+	if len(specs) == 0 {
+		return
+	}
+	if count < 1 {
+		count = 1
+	}
+	if count > len(specs) {
+		count = len(specs)
+	}
+
+	sorted := append([]string{}, specs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return weights[sorted[i]] > weights[sorted[j]]
+	})
+
+	shards = make([][]string, count)
+	loads := make([]float64, count)
+	for _, spec := range sorted {
+		lightest := 0
+		for i := range loads {
+			if loads[i] < loads[lightest] {
+				lightest = i
+			}
+		}
+		shards[lightest] = append(shards[lightest], spec)
+		loads[lightest] += weights[spec]
+	}
+	return
+}
+
+// shardsCount return the number of shards needed to run specs with at most size specs per shard
+func shardsCount(specs int, size int) int {
+	if size < 1 {
+		size = 1
+	}
+	return int(math.Ceil(float64(specs) / float64(size)))
+}
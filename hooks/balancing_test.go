@@ -0,0 +1,71 @@
+// Package hooks will manage all hooks requirements
+package hooks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimate(t *testing.T) {
+	assert := assert.New(t)
+
+	specs := []string{"known", "sized", "unknown"}
+	durations := map[string]float64{
+		"known": 100,
+	}
+	sizes := map[string]int64{
+		"known": 1000,
+		"sized": 500,
+	}
+	weights := estimate(specs, durations, sizes)
+	assert.Equal(100.0, weights["known"])
+	assert.Equal(50.0, weights["sized"])
+	assert.Equal(100.0, weights["unknown"])
+
+	weights = estimate(specs, nil, sizes)
+	assert.Equal(1000.0, weights["known"])
+	assert.Equal(500.0, weights["sized"])
+	assert.Equal(1.0, weights["unknown"])
+}
+
+func TestBalance(t *testing.T) {
+	assert := assert.New(t)
+
+	specs := []string{"a", "b", "c", "d", "e", "f"}
+	weights := map[string]float64{
+		"a": 600,
+		"b": 600,
+		"c": 600,
+		"d": 10,
+		"e": 10,
+		"f": 10,
+	}
+	shards := balance(specs, weights, 2)
+	assert.Len(shards, 2)
+
+	var loads []float64
+	total := 0
+	for _, shard := range shards {
+		load := 0.0
+		for _, spec := range shard {
+			load += weights[spec]
+		}
+		loads = append(loads, load)
+		total += len(shard)
+	}
+	assert.Equal(len(specs), total)
+	assert.InDelta(loads[0], loads[1], 600)
+	assert.Equal([]string{"a", "c"}, shards[0])
+
+	assert.Len(balance(specs[:2], weights, 5), 2)
+	assert.Nil(balance([]string{}, weights, 2))
+}
+
+func TestShardsCount(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(3, shardsCount(7, 3))
+	assert.Equal(2, shardsCount(6, 3))
+	assert.Equal(0, shardsCount(0, 3))
+}
@@ -0,0 +1,17 @@
+// Package hooks will manage all hooks requirements
+package hooks
+
+// chunk split specs in shards of at most size specs while keeping specs order
+func chunk(specs []string, size int) (shards [][]string) {
+	if size < 1 {
+		size = 1
+	}
+	for start := 0; start < len(specs); start += size {
+		end := start + size
+		if end > len(specs) {
+			end = len(specs)
+		}
+		shards = append(shards, specs[start:end])
+	}
+	return
+}
@@ -0,0 +1,18 @@
+// Package hooks will manage all hooks requirements
+package hooks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunk(t *testing.T) {
+	assert := assert.New(t)
+
+	specs := []string{"a", "b", "c", "d", "e", "f", "g"}
+	assert.Equal([][]string{{"a", "b", "c"}, {"d", "e", "f"}, {"g"}}, chunk(specs, 3))
+	assert.Equal([][]string{{"a", "b", "c"}, {"d", "e", "f"}}, chunk(specs[:6], 3))
+	assert.Equal([][]string{{"a", "b"}, {"c", "d"}, {"e"}}, chunk(specs[:5], 2))
+	assert.Nil(chunk([]string{}, 3))
+}
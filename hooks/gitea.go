@@ -0,0 +1,101 @@
+// Package hooks will manage all hooks requirements
+package hooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// giteaRepository hold repository urls sent by gitea
+type giteaRepository struct {
+	CloneURL string `json:"clone_url"`
+	HTMLURL  string `json:"html_url"`
+	SSHURL   string `json:"ssh_url"`
+}
+
+// giteaPush handle requirements of gitea push event
+type giteaPush struct {
+	Ref        string          `json:"ref"`
+	After      string          `json:"after"`
+	Repository giteaRepository `json:"repository"`
+}
+
+// giteaPullRequest handle requirements of gitea pull_request event
+type giteaPullRequest struct {
+	Action      string `json:"action"`
+	PullRequest struct {
+		Head struct {
+			Ref    string `json:"ref"`
+			SHA    string `json:"sha"`
+			RepoID int    `json:"repo_id"`
+		} `json:"head"`
+		Base struct {
+			Ref    string `json:"ref"`
+			RepoID int    `json:"repo_id"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	Repository giteaRepository `json:"repository"`
+}
+
+// Gitea handle gitea push and pull_request webhooks in order to start unit testing.
+// Events that are not supported or that do not match any project are acknowledged and ignored
+func Gitea(c *gin.Context) {
+	var (
+		w webhookEvent
+	)
+	payload, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch c.GetHeader("X-Gitea-Event") {
+	case "push":
+		var event giteaPush
+		if err := json.Unmarshal(payload, &event); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if isNullCommit(event.After) || !strings.HasPrefix(event.Ref, "refs/heads/") {
+			c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+			return
+		}
+		w.repositories = []string{event.Repository.CloneURL, event.Repository.HTMLURL, event.Repository.SSHURL}
+		w.branch = strings.TrimPrefix(event.Ref, "refs/heads/")
+		w.commit = event.After
+		w.target = w.branch
+	case "pull_request":
+		var event giteaPullRequest
+		if err := json.Unmarshal(payload, &event); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		switch event.Action {
+		case "opened", "synchronized", "reopened":
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+			return
+		}
+		// pull requests coming from forks cannot be cloned with the project settings
+		if event.PullRequest.Head.RepoID != event.PullRequest.Base.RepoID {
+			c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+			return
+		}
+		w.repositories = []string{event.Repository.CloneURL, event.Repository.HTMLURL, event.Repository.SSHURL}
+		w.branch = event.PullRequest.Head.Ref
+		w.commit = event.PullRequest.Head.SHA
+		w.target = event.PullRequest.Base.Ref
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+		return
+	}
+
+	signature := c.GetHeader("X-Gitea-Signature")
+	w.forge = "gitea"
+	w.trigger(c, func(secret string) bool {
+		return verifyHMACSHA256(secret, signature, payload)
+	})
+}
@@ -0,0 +1,101 @@
+// Package hooks will manage all hooks requirements
+package hooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// githubRepository hold repository urls sent by github
+type githubRepository struct {
+	CloneURL string `json:"clone_url"`
+	HTMLURL  string `json:"html_url"`
+	SSHURL   string `json:"ssh_url"`
+}
+
+// githubPush handle requirements of github push event
+type githubPush struct {
+	Ref        string           `json:"ref"`
+	After      string           `json:"after"`
+	Deleted    bool             `json:"deleted"`
+	Repository githubRepository `json:"repository"`
+}
+
+// githubPullRequest handle requirements of github pull_request event
+type githubPullRequest struct {
+	Action      string `json:"action"`
+	PullRequest struct {
+		Head struct {
+			Ref  string           `json:"ref"`
+			SHA  string           `json:"sha"`
+			Repo githubRepository `json:"repo"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	Repository githubRepository `json:"repository"`
+}
+
+// GitHub handle github push and pull_request webhooks in order to start unit testing.
+// Events that are not supported or that do not match any project are acknowledged and ignored
+func GitHub(c *gin.Context) {
+	var (
+		w webhookEvent
+	)
+	payload, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch c.GetHeader("X-GitHub-Event") {
+	case "push":
+		var event githubPush
+		if err := json.Unmarshal(payload, &event); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if event.Deleted || !strings.HasPrefix(event.Ref, "refs/heads/") {
+			c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+			return
+		}
+		w.repositories = []string{event.Repository.CloneURL, event.Repository.HTMLURL, event.Repository.SSHURL}
+		w.branch = strings.TrimPrefix(event.Ref, "refs/heads/")
+		w.commit = event.After
+		w.target = w.branch
+	case "pull_request":
+		var event githubPullRequest
+		if err := json.Unmarshal(payload, &event); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		switch event.Action {
+		case "opened", "synchronize", "reopened":
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+			return
+		}
+		// pull requests coming from forks cannot be cloned with the project settings
+		if normalizeRepository(event.PullRequest.Head.Repo.CloneURL) != normalizeRepository(event.Repository.CloneURL) {
+			c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+			return
+		}
+		w.repositories = []string{event.Repository.CloneURL, event.Repository.HTMLURL, event.Repository.SSHURL}
+		w.branch = event.PullRequest.Head.Ref
+		w.commit = event.PullRequest.Head.SHA
+		w.target = event.PullRequest.Base.Ref
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+		return
+	}
+
+	signature := strings.TrimPrefix(c.GetHeader("X-Hub-Signature-256"), "sha256=")
+	w.forge = "github"
+	w.trigger(c, func(secret string) bool {
+		return verifyHMACSHA256(secret, signature, payload)
+	})
+}
@@ -0,0 +1,107 @@
+// Package hooks will manage all hooks requirements
+package hooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gitlabProject hold project urls sent by gitlab
+type gitlabProject struct {
+	GitHTTPURL string `json:"git_http_url"`
+	GitSSHURL  string `json:"git_ssh_url"`
+	WebURL     string `json:"web_url"`
+}
+
+// gitlabPush handle requirements of gitlab push hook
+type gitlabPush struct {
+	Ref     string        `json:"ref"`
+	After   string        `json:"after"`
+	Project gitlabProject `json:"project"`
+}
+
+// gitlabMergeRequest handle requirements of gitlab merge request hook
+type gitlabMergeRequest struct {
+	Project          gitlabProject `json:"project"`
+	ObjectAttributes struct {
+		Action          string `json:"action"`
+		OldRev          string `json:"oldrev"`
+		SourceBranch    string `json:"source_branch"`
+		TargetBranch    string `json:"target_branch"`
+		SourceProjectID int    `json:"source_project_id"`
+		TargetProjectID int    `json:"target_project_id"`
+		LastCommit      struct {
+			ID string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+}
+
+// GitLab handle gitlab push and merge request hooks in order to start unit testing.
+// Events that are not supported or that do not match any project are acknowledged and ignored
+func GitLab(c *gin.Context) {
+	var (
+		w webhookEvent
+	)
+	payload, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch c.GetHeader("X-Gitlab-Event") {
+	case "Push Hook":
+		var event gitlabPush
+		if err := json.Unmarshal(payload, &event); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if isNullCommit(event.After) || !strings.HasPrefix(event.Ref, "refs/heads/") {
+			c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+			return
+		}
+		w.repositories = []string{event.Project.GitHTTPURL, event.Project.WebURL, event.Project.GitSSHURL}
+		w.branch = strings.TrimPrefix(event.Ref, "refs/heads/")
+		w.commit = event.After
+		w.target = w.branch
+	case "Merge Request Hook":
+		var event gitlabMergeRequest
+		if err := json.Unmarshal(payload, &event); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		switch event.ObjectAttributes.Action {
+		case "open", "reopen":
+		case "update":
+			// update action is also sent when title or description change
+			// but oldrev is only provided when new commits have been pushed
+			if event.ObjectAttributes.OldRev == "" {
+				c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+				return
+			}
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+			return
+		}
+		// merge requests coming from forks cannot be cloned with the project settings
+		if event.ObjectAttributes.SourceProjectID != event.ObjectAttributes.TargetProjectID {
+			c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+			return
+		}
+		w.repositories = []string{event.Project.GitHTTPURL, event.Project.WebURL, event.Project.GitSSHURL}
+		w.branch = event.ObjectAttributes.SourceBranch
+		w.commit = event.ObjectAttributes.LastCommit.ID
+		w.target = event.ObjectAttributes.TargetBranch
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+		return
+	}
+
+	token := c.GetHeader("X-Gitlab-Token")
+	w.forge = "gitlab"
+	w.trigger(c, func(secret string) bool {
+		return verifyToken(secret, token)
+	})
+}
@@ -4,7 +4,6 @@ package hooks
 import (
 	"crypto/md5"
 	"fmt"
-	"math"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -14,24 +13,34 @@ import (
 	"time"
 
 	"github.com/Lord-Y/cypress-parallel-api/commons"
+	"github.com/Lord-Y/cypress-parallel-api/discovery"
+	"github.com/Lord-Y/cypress-parallel-api/encryption"
+	"github.com/Lord-Y/cypress-parallel-api/events"
 	"github.com/Lord-Y/cypress-parallel-api/git"
 	"github.com/Lord-Y/cypress-parallel-api/kubernetes"
 	"github.com/Lord-Y/cypress-parallel-api/models"
-	"github.com/Lord-Y/cypress-parallel-api/tools"
 	"github.com/gin-gonic/gin"
 	"github.com/mitchellh/mapstructure"
 	"github.com/rs/zerolog/log"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8s "k8s.io/client-go/kubernetes"
 )
 
 // plain struct handle requirements to start unit testing
 type plain struct {
 	ProjectName          string `form:"project_name" json:"project_name" binding:"required,max=100"`
 	Branch               string `form:"branch" json:"branch"`
+	Commit               string `form:"commit" json:"commit" binding:"max=100"` // commit SHA or tag to test instead of the head of the branch
 	Specs                string `form:"specs" json:"specs"`
 	ConfigFile           string `form:"config_file,default=cypress.json" json:"config_file" binding:"max=100"`
 	Browser              string `form:"browser,default=chrome" json:"browser" binding:"max=100,oneof=chrome firefox"`
-	MaxPods              int    `form:"maxPods,default=10" json:"maxPods"`
+	MaxPods              int    `form:"maxPods" json:"maxPods"` // project max pods are used when not provided
 	CypressDockerVersion string `form:"cypress_docker_version,default=7.2.0-0.0.5,max=20" json:"cypress_docker_version"`
+	DryRun               bool   `form:"dryRun" json:"dryRun"`
+	TriggeredBy          string `form:"triggered_by" json:"triggered_by" binding:"max=100"`
+	parentUniqID         string // uniq id of the run retried if any
+
+	clientset k8s.Interface // kubernetes client used to create pods
 }
 
 // projects will be use to "mapstructure" data from db
@@ -52,34 +61,115 @@ type projects struct {
 	Username               string
 	Password               string
 	Browser                string
+	Kubernetes_backend     string
+	Balancing              string
+	Cpu_request            string
+	Cpu_limit              string
+	Memory_request         string
+	Memory_limit           string
+	Node_selector          string
+	Tolerations            string
+	Affinity               string
+	Image_repository       string
+	Image                  string
+	Image_pull_secrets     string
+	Ssh_private_key        string
+	Ssh_known_hosts        string
+	Specs_include          string
+	Specs_exclude          string
+}
+
+// run handle all requirements to insert run in DB
+type run struct {
+	uniqID               string
+	projectID            int
+	runStatus            string // must be, PENDING, LAUNCHING, LAUNCHED, FAILED, CANCELLED
+	branch               string
+	specs                string // specs requested, the project ones are used when empty
+	browser              string
+	configFile           string
+	cypressDockerVersion string
+	maxPods              int
+	parentUniqID         string
+	triggeredBy          string // who or what launched the run like api, scheduling, retry or the git forge
+	requestedCommit      string // commit SHA or tag requested, the head of the branch is used when empty
 }
 
 // execution handle all requirements to insert execution in DB
 type execution struct {
-	projectID       int
-	uniqID          string
-	branch          string
-	executionStatus string // must be, NOT_STARTED, QUEUED, SCHEDULED, RUNNING, CANCELLED, FAILED, DONE
-	spec            string
-	result          string
+	projectID            int
+	uniqID               string
+	branch               string
+	executionStatus      string // must be, NOT_STARTED, QUEUED, SCHEDULED, RUNNING, CANCELLED, FAILED, DONE
+	spec                 string
+	result               string
+	browser              string
+	configFile           string
+	cypressDockerVersion string
+	parentUniqID         string
+	shard                int // index of the shard in which the spec runs
 }
 
 // updatePodName will be used to update pod name in DB
 type updatePodName struct {
 	podName string
+	jobName string
 	uniqID  string
 	spec    string
 }
 
 // executionQueue will be use to "mapstructure" data from db
 type executionQueue struct {
-	Execution_id     string
-	Project_name     string
-	Project_id       string
-	Branch           string
-	Execution_status string
-	Uniq_id          string
-	Spec             string
+	Execution_id           string
+	Project_name           string
+	Project_id             string
+	Branch                 string
+	Execution_status       string
+	Uniq_id                string
+	Spec                   string
+	Browser                string
+	Config_file            string
+	Cypress_docker_version string
+	Shard                  string
+	Commit_sha             string
+	Max_pods               string
+}
+
+// failedExecutions will be use to "mapstructure" data from db
+type failedExecutions struct {
+	Project_name           string
+	Branch                 string
+	Spec                   string
+	Browser                string
+	Config_file            string
+	Cypress_docker_version string
+	Commit_sha             string
+}
+
+// resolved hold what a launch needs once the repository is cloned
+type resolved struct {
+	project projects
+	branch  string
+	commit  string
+	specs   []string
+	sizes   map[string]int64 // specs file sizes
+}
+
+// plan hold the shards a launch would create without starting it
+type plan struct {
+	Branch string         `json:"branch"`
+	Commit string         `json:"commit"`
+	Specs  []string       `json:"specs"`
+	Shards []plannedShard `json:"shards"`
+}
+
+// plannedShard hold the specs of a shard, its initial execution status
+// and the kubernetes pod or job that would run it
+type plannedShard struct {
+	Shard    int         `json:"shard"`
+	Specs    []string    `json:"specs"`
+	Status   string      `json:"status"`
+	Manifest interface{} `json:"manifest"`
 }
 
 var (
@@ -89,53 +179,294 @@ var (
 	}
 )
 
-const (
-	ghr = "docker.pkg.github.com/lord-y/cypress-parallel-docker-images/cypress-parallel-docker-images"
-)
+// sshMountPath is the directory in which the SSH private key
+// and known hosts of the project are mounted in pods
+const sshMountPath = "/etc/cypress-parallel/ssh"
 
 // Plain handle requirements to start unit testing
 func Plain(c *gin.Context) {
 	var (
-		p           plain
-		pj          projects
-		ex          execution
-		pod         models.Pods
-		gitc        git.Repository
-		targetSpecs string
-		branch      string
-		specs       []string
-		tmpSecs     []string
-		finalSecs   []string
-		nbSpec      int
-		reset       bool
+		p plain
 	)
 	if err := c.ShouldBind(&p); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if p.DryRun {
+		z, statusCode, err := p.plan()
+		if err != nil {
+			if statusCode == http.StatusBadRequest {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+			}
+			return
+		}
+		c.JSON(http.StatusOK, z)
+		return
+	}
+
+	clientset, err := kubernetes.ClientFrom(c)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while initializing kubernetes client")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	p.clientset = clientset
+
+	uniqID, statusCode, err := p.launch()
+	if err != nil {
+		if statusCode == http.StatusBadRequest {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		}
+		return
+	}
+	statusURL := fmt.Sprintf("%s/api/v1/cypress-parallel-api/runs/%s", commons.GetAPIUrl(), uniqID)
+	c.Header("Location", statusURL)
+	c.JSON(http.StatusAccepted, gin.H{"uniqId": uniqID, "statusUrl": statusURL})
+}
+
+// Retry launch a new run with the failed specs of the uniq id provided.
+// The new run keeps the branch, commit, browser, config file and docker version of the former one
+// and is linked to it with parent_uniq_id
+func Retry(clientset k8s.Interface, uniqID string) (z string, statusCode int, err error) {
+	var (
+		failed []failedExecutions
+		specs  []string
+	)
+	result, err := getFailedExecutions(uniqID)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		return z, http.StatusInternalServerError, err
+	}
+	err = mapstructure.Decode(result, &failed)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while decoding structure")
+		return z, http.StatusInternalServerError, err
+	}
+	if len(failed) == 0 {
+		return z, http.StatusNotFound, fmt.Errorf("No failed executions found for uniq id %s", uniqID)
+	}
+
+	for _, v := range failed {
+		specs = append(specs, v.Spec)
+	}
+	p := plain{
+		ProjectName:          failed[0].Project_name,
+		Branch:               failed[0].Branch,
+		Commit:               failed[0].Commit_sha,
+		Specs:                strings.Join(specs, ","),
+		Browser:              failed[0].Browser,
+		ConfigFile:           failed[0].Config_file,
+		CypressDockerVersion: failed[0].Cypress_docker_version,
+		TriggeredBy:          "retry",
+		parentUniqID:         uniqID,
+		clientset:            clientset,
+	}
+
+	pj, statusCode, err := p.project()
+	if err != nil {
+		return z, statusCode, err
+	}
+	// specs are already known so there is nothing to clone and the run is started right away
+	z, err = p.createRun(pj, "LAUNCHING")
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		return z, http.StatusInternalServerError, err
+	}
+	err = updateRunCommit(z, p.Commit)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing update db query")
+		return z, http.StatusInternalServerError, err
+	}
+	statusCode, err = p.start(pj, z, failed[0].Branch, p.Commit, specs, nil)
+	launched(z, statusCode, err)
+	return z, statusCode, err
+}
+
+// project retrieve the project to launch and set settings
+// not provided by the caller with the project ones
+func (p *plain) project() (pj projects, statusCode int, err error) {
+	result, err := p.getProjectInfos()
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		return pj, http.StatusInternalServerError, err
+	}
+	err = mapstructure.Decode(result, &pj)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while decoding structure")
+		return pj, http.StatusInternalServerError, err
+	}
+	if pj.Project_id == "" {
+		return pj, http.StatusBadRequest, fmt.Errorf("Project %s not found", p.ProjectName)
+	}
+	pj.Password, err = encryption.Decrypt(pj.Password)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error occured while decrypting password of project %s", p.ProjectName)
+		return pj, http.StatusInternalServerError, err
+	}
+	pj.Ssh_private_key, err = encryption.Decrypt(pj.Ssh_private_key)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error occured while decrypting SSH private key of project %s", p.ProjectName)
+		return pj, http.StatusInternalServerError, err
+	}
+
+	if p.CypressDockerVersion == "" {
+		p.CypressDockerVersion = pj.Cypress_docker_version
+	}
+	if p.ConfigFile == "" {
+		p.ConfigFile = pj.Config_file
+	}
+	if p.Browser == "" {
+		p.Browser = pj.Browser
+	}
+	if p.MaxPods == 0 && pj.Max_pods != "" {
+		p.MaxPods, err = strconv.Atoi(pj.Max_pods)
+		if err != nil {
+			log.Error().Err(err).Msg("Error occured while converting string to int")
+			return pj, http.StatusInternalServerError, err
+		}
+	}
+
 	if p.CypressDockerVersion == "" {
 		p.CypressDockerVersion = "7.2.0-0.0.5"
 	}
 	if p.ConfigFile == "" {
 		p.ConfigFile = "cypress.json"
 	}
+	if p.Browser == "" {
+		p.Browser = "chrome"
+	}
 	if p.MaxPods == 0 {
 		p.MaxPods = 10
 	}
+	return pj, http.StatusOK, nil
+}
 
-	result, err := p.getProjectInfos()
+// launch persist a PENDING run of the project and hand it to launch workers
+// which will clone the repository, retrieve specs and create pods accordingly.
+// It returns http status code alongside the error so the caller can decide what to do with it
+func (p *plain) launch() (uniqID string, statusCode int, err error) {
+	pj, statusCode, err := p.project()
+	if err != nil {
+		return uniqID, statusCode, err
+	}
+	uniqID, err = p.createRun(pj, "PENDING")
 	if err != nil {
 		log.Error().Err(err).Msg("Error occured while performing db query")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return uniqID, http.StatusInternalServerError, err
+	}
+	enqueue(uniqID, p.clientset)
+	return uniqID, http.StatusAccepted, nil
+}
+
+// createRun persist a new run of the project with the status provided and return its uniq id
+func (p *plain) createRun(pj projects, status string) (uniqID string, err error) {
+	projectID, err := strconv.Atoi(pj.Project_id)
+	if err != nil {
 		return
 	}
-	err = mapstructure.Decode(result, &pj)
+	uniqID = newUniqID(pj.Project_name)
+	if p.TriggeredBy == "" {
+		p.TriggeredBy = "api"
+	}
+	r := run{
+		uniqID:               uniqID,
+		projectID:            projectID,
+		runStatus:            status,
+		branch:               p.Branch,
+		specs:                p.Specs,
+		browser:              p.Browser,
+		configFile:           p.ConfigFile,
+		cypressDockerVersion: p.CypressDockerVersion,
+		maxPods:              p.MaxPods,
+		parentUniqID:         p.parentUniqID,
+		triggeredBy:          p.TriggeredBy,
+		requestedCommit:      p.Commit,
+	}
+	err = r.create()
 	if err != nil {
-		log.Error().Err(err).Msg("Error occured while decoding structure")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
 		return
 	}
+	events.Publish(events.Event{
+		Type:      "run",
+		UniqID:    uniqID,
+		ProjectID: projectID,
+		Status:    status,
+	})
+	return
+}
+
+// execute clone the repository, retrieve specs and create executions and pods of the run provided.
+// It returns http status code alongside the error so the caller can decide what to do with it
+func (p *plain) execute(uniqID string) (statusCode int, err error) {
+	r, statusCode, err := p.resolve()
+	if err != nil {
+		return statusCode, err
+	}
+	err = updateRunCommit(uniqID, r.commit)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing update db query")
+		return http.StatusInternalServerError, err
+	}
+	return p.start(r.project, uniqID, r.branch, r.commit, r.specs, r.sizes)
+}
+
+// plan clone the repository, retrieve specs and return the shards and the pods or jobs
+// a launch would create. Nothing is written in db nor created in kubernetes
+func (p *plain) plan() (z plan, statusCode int, err error) {
+	r, statusCode, err := p.resolve()
+	if err != nil {
+		return z, statusCode, err
+	}
+
+	shards, err := r.project.shards(r.specs, r.sizes)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		return z, http.StatusInternalServerError, err
+	}
+	uniqID := newUniqID(r.project.Project_name)
+
+	z.Branch = r.branch
+	z.Commit = r.commit
+	z.Specs = r.specs
+	for count, shard := range shards {
+		pod, err := r.project.pod(*p, uniqID, r.branch, r.commit, strings.Join(shard, ","))
+		if err != nil {
+			log.Error().Err(err).Msg("Error occured while performing db query")
+			return z, http.StatusInternalServerError, err
+		}
+		manifest, err := r.project.manifest(pod)
+		if err != nil {
+			log.Error().Err(err).Msg("Error occured while generating kubernetes manifest")
+			return z, http.StatusInternalServerError, err
+		}
+		z.Shards = append(z.Shards, plannedShard{
+			Shard:    count,
+			Specs:    shard,
+			Status:   p.shardStatus(count),
+			Manifest: manifest,
+		})
+	}
+	return z, http.StatusOK, nil
+}
+
+// resolve clone the branch or the commit requested of the repository of the project and find the specs to launch
+func (p *plain) resolve() (r resolved, statusCode int, err error) {
+	var (
+		gitc        git.Repository
+		targetSpecs string
+		branch      string
+		specs       []string
+	)
+
+	pj, statusCode, err := p.project()
+	if err != nil {
+		return r, statusCode, err
+	}
 
 	// original branch must remain for POST "executions" with update db otherwise, pod will stay up forever
 	if p.Branch != "" {
@@ -158,17 +489,18 @@ func Plain(c *gin.Context) {
 	gitc.Repository = pj.Repository
 	gitc.Username = pj.Username
 	gitc.Password = pj.Password
+	gitc.SSHPrivateKey = pj.Ssh_private_key
+	gitc.SSHKnownHosts = pj.sshKnownHosts()
+	gitc.Revision = p.Commit
 
-	gitdir, statusCode, err := gitc.Clone()
+	gitdir, commit, statusCode, err := gitc.Clone()
 	defer os.RemoveAll(gitdir)
 	if err != nil {
-		msg := fmt.Sprintf("Error occured while cloning git repository, error: %s", err.Error())
-		if statusCode == 400 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if statusCode == http.StatusBadRequest {
+			return r, statusCode, fmt.Errorf("Error occured while cloning git repository, error: %s", err.Error())
 		}
-		return
+		log.Error().Err(err).Msg("Error occured while cloning git repository")
+		return r, http.StatusInternalServerError, err
 	}
 
 	if p.Specs != "" {
@@ -177,234 +509,449 @@ func Plain(c *gin.Context) {
 		targetSpecs = pj.Specs
 	}
 
-	if strings.HasSuffix(targetSpecs, ".spec.js") || strings.HasSuffix(targetSpecs, ".ts") {
-		err := tools.CheckIsFile(fmt.Sprintf("%s/%s", gitdir, targetSpecs))
-		if err != nil {
-			msg := fmt.Sprintf("Error occured while retrieving specs, error: %s", err.Error())
-			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
-			return
-		}
-		specs = append(specs, targetSpecs)
-	} else {
-		err := filepath.Walk(fmt.Sprintf("%s/%s", gitdir, targetSpecs), func(file string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if info.Mode().IsRegular() && (strings.HasSuffix(file, ".spec.js") || strings.HasSuffix(file, ".ts")) {
-				specs = append(specs, strings.ReplaceAll(file, fmt.Sprintf("%s/", gitdir), ""))
-			}
-			return nil
-		})
-		if err != nil {
-			msg := fmt.Sprintf("Error occured while retrieving specs, error: %s", err.Error())
-			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
-			return
+	specs, err = discovery.Find(gitdir, targetSpecs, discovery.SplitPatterns(pj.Specs_include), discovery.SplitPatterns(pj.Specs_exclude))
+	if err != nil {
+		return r, http.StatusBadRequest, fmt.Errorf("Error occured while retrieving specs, error: %s", err.Error())
+	}
+	if len(specs) == 0 {
+		return r, http.StatusBadRequest, fmt.Errorf("No specs found in %s", targetSpecs)
+	}
+	sizes := make(map[string]int64)
+	for _, spec := range specs {
+		info, err := os.Stat(filepath.Join(gitdir, spec))
+		if err == nil {
+			sizes[spec] = info.Size()
 		}
 	}
+	return resolved{
+		project: pj,
+		branch:  branch,
+		commit:  commit,
+		specs:   specs,
+		sizes:   sizes,
+	}, http.StatusOK, nil
+}
+
+// shards split specs into the shards that will each run in a pod.
+// With duration balancing, specs are bin-packed according to their past durations
+// so every pod finishes at roughly the same time, otherwise they are chunked in order
+func (pj *projects) shards(specs []string, sizes map[string]int64) (shards [][]string, err error) {
+	if pj.Balancing != "duration" {
+		return chunk(specs, commons.GetMaxSpecs()), nil
+	}
 
-	clientset, err := kubernetes.Client()
+	result, err := getSpecDurations(pj.Project_id)
 	if err != nil {
-		log.Error().Err(err).Msg("Error occured while initializing kubernetes client")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
 		return
 	}
+	durations := make(map[string]float64)
+	for _, v := range result {
+		duration, err := strconv.ParseFloat(fmt.Sprintf("%s", v["duration"]), 64)
+		if err == nil {
+			durations[fmt.Sprintf("%s", v["spec"])] = duration
+		}
+	}
+	return balance(specs, estimate(specs, durations, sizes), shardsCount(len(specs), commons.GetMaxSpecs())), nil
+}
+
+// start create executions of the specs provided for the run of the uniq id provided
+// and the pods required to run them at the commit provided.
+// Specs are split into shards according to the project balancing and
+// shards exceeding max pods are QUEUED. sizes are the specs file sizes if known
+func (p *plain) start(pj projects, uniqID string, branch string, commit string, specs []string, sizes map[string]int64) (statusCode int, err error) {
+	var (
+		ex        execution
+		finalSecs []string
+	)
+
+	clientset := p.clientset
 	err = kubernetes.GetNamespace(clientset, commons.GetKubernetesJobsNamespace())
 	if err != nil {
 		log.Warn().Err(err).Msg("Error occured while getting kubernetes namespace")
 		err = kubernetes.CreateNamespace(clientset, commons.GetKubernetesJobsNamespace())
 		if err != nil {
 			log.Error().Err(err).Msg("Error occured while creating kubernetes namespace")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
-			return
+			return http.StatusInternalServerError, err
 		}
 	}
 	err = kubernetes.GetServiceAccountName(clientset, commons.GetKubernetesJobsNamespace(), commons.GetKubernetesJobsNamespace())
 	if err != nil {
 		log.Warn().Err(err).Msgf("Error occured while getting kubernetes service account %s", commons.GetKubernetesJobsNamespace())
-		_, err = kubernetes.CreateServiceAccountName(clientset, commons.GetKubernetesJobsNamespace(), commons.GetKubernetesJobsNamespace())
+		_, err = kubernetes.CreateServiceAccountName(clientset, commons.GetKubernetesJobsNamespace(), commons.GetKubernetesJobsNamespace(), commons.GetKubernetesJobsImagePullSecrets()...)
 		if err != nil {
 			log.Error().Err(err).Msgf("Error occured while creating kubernetes service account %s", commons.GetKubernetesJobsNamespace())
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
-			return
+			return http.StatusInternalServerError, err
 		}
 	}
+	err = kubernetes.SetServiceAccountImagePullSecrets(clientset, commons.GetKubernetesJobsNamespace(), commons.GetKubernetesJobsNamespace(), commons.GetKubernetesJobsImagePullSecrets()...)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error occured while setting image pull secrets of kubernetes service account %s", commons.GetKubernetesJobsNamespace())
+		return http.StatusInternalServerError, err
+	}
 
-	for k, v := range specs {
-		tmpSecs = append(tmpSecs, v)
-		if (nbSpec + 1) == commons.GetMaxSpecs() {
-			finalSecs = append(finalSecs, strings.Join(tmpSecs, ","))
-			tmpSecs = nil
-			nbSpec = 0
-			reset = true
-		}
-		if !reset {
-			nbSpec++
-		}
-		if nbSpec == 0 {
-			reset = false
-		}
-		if k == len(specs)-1 && commons.GetMaxSpecs()%2 == 1 {
-			finalSecs = append(finalSecs, strings.Join(tmpSecs, ","))
-		}
+	err = pj.applySecret(clientset)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error occured while applying kubernetes secret of project %s", pj.Project_id)
+		return http.StatusInternalServerError, err
+	}
+
+	shards, err := pj.shards(specs, sizes)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		return http.StatusInternalServerError, err
+	}
+	for _, shard := range shards {
+		finalSecs = append(finalSecs, strings.Join(shard, ","))
 	}
 
-	uniqID := md5.Sum([]byte(fmt.Sprintf("%s%s%s", pj.Repository, finalSecs, time.Now())))
-	runidID := fmt.Sprintf("%x", uniqID)
-	uniqID_ := runidID[0:10]
+	projecID, err := strconv.Atoi(pj.Project_id)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while converting string to int")
+		return http.StatusInternalServerError, err
+	}
 
 	for count, spec := range finalSecs {
 		var (
-			pdn     updatePodName
-			tag     string
-			command []string
+			pdn    updatePodName
+			status string
 		)
-		projecID, err := strconv.Atoi(pj.Project_id)
+
+		// the run may be cancelled while its shards are created
+		status, err = getRunStatus(uniqID)
 		if err != nil {
-			log.Error().Err(err).Msg("Error occured while converting string to int")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
-			return
+			log.Error().Err(err).Msg("Error occured while performing db query")
+			return http.StatusInternalServerError, err
+		}
+		if status == "CANCELLED" {
+			log.Info().Msgf("Run %s was cancelled, %d shards left are not started", uniqID, len(finalSecs)-count)
+			return http.StatusOK, nil
 		}
 
-		if count < p.MaxPods {
-			for _, splittedSpec := range strings.Split(spec, ",") {
-				ex.projectID = projecID
-				ex.uniqID = uniqID_
-				ex.executionStatus = "NOT_STARTED"
-				ex.spec = splittedSpec
-				ex.result = `{}`
-				ex.branch = branch
-
-				_, err = ex.create()
-				if err != nil {
-					log.Error().Err(err).Msg("Error occured while performing db query")
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
-					return
-				}
-			}
-
-			annotations, err := pj.getProjectAnnotations()
+		ex.projectID = projecID
+		ex.uniqID = uniqID
+		ex.result = `{}`
+		ex.branch = branch
+		ex.browser = p.Browser
+		ex.configFile = p.ConfigFile
+		ex.cypressDockerVersion = p.CypressDockerVersion
+		ex.parentUniqID = p.parentUniqID
+		ex.shard = count
+		ex.executionStatus = p.shardStatus(count)
+		for _, splittedSpec := range strings.Split(spec, ",") {
+			ex.spec = splittedSpec
+			_, err = ex.create()
 			if err != nil {
 				log.Error().Err(err).Msg("Error occured while performing db query")
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
-				return
-			}
-			if len(annotations) > 0 {
-				annotation := make(map[string]string)
-				for _, k := range annotations {
-					annotation[fmt.Sprintf("%s", k["key"])] = fmt.Sprintf("%s", k["value"])
-				}
-				pod.Annotations = annotation
+				return http.StatusInternalServerError, err
 			}
+			events.Publish(events.Event{
+				Type:      "execution",
+				UniqID:    uniqID,
+				ProjectID: projecID,
+				Spec:      splittedSpec,
+				Status:    ex.executionStatus,
+			})
+		}
+		if ex.executionStatus == "QUEUED" {
+			continue
+		}
+
+		pod, err := pj.pod(*p, uniqID, branch, commit, spec)
+		if err != nil {
+			log.Error().Err(err).Msg("Error occured while performing db query")
+			return http.StatusInternalServerError, err
+		}
 
-			envVars, err := pj.getProjectEnvironments()
+		podName, jobName, err := pj.run(clientset, pod)
+		if err != nil {
+			log.Error().Err(err).Msg("Error occured while creating pod")
+			return http.StatusInternalServerError, err
+		}
+		log.Debug().Msgf("Pod name %s job name %s created", podName, jobName)
+
+		for _, splittedSpec := range strings.Split(spec, ",") {
+			pdn.podName = podName
+			pdn.jobName = jobName
+			pdn.uniqID = uniqID
+			pdn.spec = splittedSpec
+
+			updated, err := pdn.update()
 			if err != nil {
-				log.Error().Err(err).Msg("Error occured while performing db query")
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
-				return
-			}
-			if len(envVars) > 0 {
-				var (
-					envs   []models.EnvironmentVar
-					envVar models.EnvironmentVar
-				)
-				for _, k := range envVars {
-					envVar.Key = fmt.Sprintf("CYPRESS_%s", k["key"])
-					envVar.Value = fmt.Sprintf("%s", k["value"])
-					envs = append(envs, envVar)
-				}
-				if strings.TrimSpace(os.Getenv("CYPRESS_PARALLEL_CLI_LOG_LEVEL")) != "" {
-					envVar.Key = "CYPRESS_PARALLEL_CLI_LOG_LEVEL"
-					envVar.Value = strings.TrimSpace(os.Getenv("CYPRESS_PARALLEL_CLI_LOG_LEVEL"))
-					envs = append(envs, envVar)
-				}
-				envVar.Key = "NO_COLOR"
-				envVar.Value = "1"
-				envs = append(envs, envVar)
-				pod.Container.EnvironmentVars = envs
-			}
-			pod.Namespace = commons.GetKubernetesJobsNamespace()
-			pod.GenerateName = "cypress-parallel-jobs-"
-			pod.Labels = commonLabels
-
-			command = append(command, "cypress-parallel-cli")
-			command = append(command, "cypress")
-			command = append(command, "--browser")
-			command = append(command, p.Browser)
-			command = append(command, "--config-file")
-			command = append(command, p.ConfigFile)
-			command = append(command, "--specs")
-			command = append(command, spec)
-			command = append(command, "--uid")
-			command = append(command, uniqID_)
-			command = append(command, "--branch")
-			command = append(command, branch)
-			command = append(command, "--repository")
-			command = append(command, gitc.Repository)
-			command = append(command, "--api-url")
-			command = append(command, commons.GetAPIUrl())
-			command = append(command, "--report-back")
-			command = append(command, "--timeout")
-			command = append(command, pj.Timeout)
-			if pj.Username != "" {
-				command = append(command, "--username")
-				command = append(command, pj.Username)
+				log.Error().Err(err).Msg("Error occured while performing update db query")
+				return http.StatusInternalServerError, err
 			}
-			if pj.Password != "" {
-				command = append(command, "--password")
-				command = append(command, pj.Password)
-			}
-			if p.CypressDockerVersion != "" {
-				tag = p.CypressDockerVersion
-			} else {
-				tag = pj.Cypress_docker_version
+			if updated {
+				pdn.publish(projecID)
 			}
+		}
+	}
+	return http.StatusCreated, nil
+}
 
-			pod.Container.Command = command
-			pod.Container.Name = "cypress-parallel-jobs"
-			pod.Container.Image = fmt.Sprintf("%s:%s", ghr, tag)
+// publish notify the pod assignment of the execution to events subscribers
+func (p *updatePodName) publish(projectID int) {
+	events.Publish(events.Event{
+		Type:      "pod",
+		UniqID:    p.uniqID,
+		ProjectID: projectID,
+		Spec:      p.spec,
+		Status:    "RUNNING",
+		PodName:   p.podName,
+		JobName:   p.jobName,
+	})
+}
 
-			podName, err := kubernetes.CreatePod(clientset, pod)
-			if err != nil {
-				log.Error().Err(err).Msg("Error occured while creating pod")
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
-				return
-			}
-			log.Debug().Msgf("Pod name %s created", podName)
+// newUniqID return the uniq id of a new run of the project provided
+func newUniqID(projectName string) string {
+	sum := md5.Sum([]byte(fmt.Sprintf("%s%s", projectName, time.Now())))
+	return fmt.Sprintf("%x", sum)[0:10]
+}
 
-			for _, splittedSpec := range strings.Split(spec, ",") {
-				pdn.podName = podName
-				pdn.uniqID = uniqID_
-				pdn.spec = splittedSpec
+// shardStatus return the initial execution status of the shard provided.
+// Shards exceeding max pods are QUEUED until pods are available
+func (p *plain) shardStatus(shard int) string {
+	if shard < p.MaxPods {
+		return "NOT_STARTED"
+	}
+	return "QUEUED"
+}
 
-				err = pdn.update()
-				if err != nil {
-					log.Error().Err(err).Msg("Error occured while performing update db query")
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
-					return
-				}
-			}
-		} else {
-			for _, splittedSpec := range strings.Split(spec, ",") {
-				ex.projectID = projecID
-				ex.uniqID = uniqID_
-				ex.executionStatus = "QUEUED"
-				ex.spec = splittedSpec
-				ex.result = `{}`
-				ex.branch = branch
-
-				_, err = ex.create()
-				if err != nil {
-					log.Error().Err(err).Msg("Error occured while performing db query")
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
-					return
-				}
-			}
+// pod return the pod configuration that will run the specs provided.
+// Pods checkout the commit provided when set so they test what the api resolved, whatever was pushed since
+func (pj *projects) pod(p plain, uniqID string, branch string, commit string, specs string) (pod models.Pods, err error) {
+	var (
+		command []string
+	)
+	annotations, err := pj.getProjectAnnotations()
+	if err != nil {
+		return
+	}
+	if len(annotations) > 0 {
+		annotation := make(map[string]string)
+		for _, k := range annotations {
+			annotation[fmt.Sprintf("%s", k["key"])] = fmt.Sprintf("%s", k["value"])
+		}
+		pod.Annotations = annotation
+	}
+
+	envVars, err := pj.getProjectEnvironments()
+	if err != nil {
+		return
+	}
+	if len(envVars) > 0 {
+		var (
+			envs   []models.EnvironmentVar
+			envVar models.EnvironmentVar
+		)
+		for _, k := range envVars {
+			envVar.Key = fmt.Sprintf("CYPRESS_%s", k["key"])
+			envVar.Value = fmt.Sprintf("%s", k["value"])
+			envs = append(envs, envVar)
+		}
+		if strings.TrimSpace(os.Getenv("CYPRESS_PARALLEL_CLI_LOG_LEVEL")) != "" {
+			envVar.Key = "CYPRESS_PARALLEL_CLI_LOG_LEVEL"
+			envVar.Value = strings.TrimSpace(os.Getenv("CYPRESS_PARALLEL_CLI_LOG_LEVEL"))
+			envs = append(envs, envVar)
+		}
+		envVar.Key = "NO_COLOR"
+		envVar.Value = "1"
+		envs = append(envs, envVar)
+		pod.Container.EnvironmentVars = envs
+	}
+	pod.Namespace = commons.GetKubernetesJobsNamespace()
+	pod.GenerateName = "cypress-parallel-jobs-"
+	pod.Labels = commonLabels
+
+	command = append(command, "cypress-parallel-cli")
+	command = append(command, "cypress")
+	command = append(command, "--browser")
+	command = append(command, p.Browser)
+	command = append(command, "--config-file")
+	command = append(command, p.ConfigFile)
+	command = append(command, "--specs")
+	command = append(command, specs)
+	command = append(command, "--uid")
+	command = append(command, uniqID)
+	command = append(command, "--branch")
+	command = append(command, branch)
+	if commit != "" {
+		command = append(command, "--commit")
+		command = append(command, commit)
+	}
+	command = append(command, "--repository")
+	command = append(command, pj.Repository)
+	command = append(command, "--api-url")
+	command = append(command, commons.GetAPIUrl())
+	command = append(command, "--report-back")
+	command = append(command, "--timeout")
+	command = append(command, pj.Timeout)
+	// credentials are read from the project secret and expanded by kubernetes
+	// so they don't show up in the pod spec
+	if pj.Username != "" {
+		command = append(command, "--username")
+		command = append(command, "$(GIT_USERNAME)")
+		pod.Container.EnvironmentVars = append(pod.Container.EnvironmentVars, models.EnvironmentVar{Key: "GIT_USERNAME", SecretName: kubernetes.ProjectSecretName(pj.Project_id), SecretKey: "username"})
+	}
+	if pj.Password != "" {
+		command = append(command, "--password")
+		command = append(command, "$(GIT_PASSWORD)")
+		pod.Container.EnvironmentVars = append(pod.Container.EnvironmentVars, models.EnvironmentVar{Key: "GIT_PASSWORD", SecretName: kubernetes.ProjectSecretName(pj.Project_id), SecretKey: "password"})
+	}
+	// SSH private key and known hosts are mounted from the project secret
+	if pj.Ssh_private_key != "" {
+		volume := models.SecretVolume{
+			Name:       "git-ssh",
+			SecretName: kubernetes.ProjectSecretName(pj.Project_id),
+			MountPath:  sshMountPath,
+			Keys:       []string{"ssh-privatekey"},
+		}
+		command = append(command, "--ssh-private-key")
+		command = append(command, fmt.Sprintf("%s/ssh-privatekey", sshMountPath))
+		if pj.sshKnownHosts() != "" {
+			volume.Keys = append(volume.Keys, "known_hosts")
+			command = append(command, "--ssh-known-hosts")
+			command = append(command, fmt.Sprintf("%s/known_hosts", sshMountPath))
+		}
+		pod.Container.SecretVolumes = append(pod.Container.SecretVolumes, volume)
+	}
+
+	pod.Container.Command = command
+	pod.Container.Name = "cypress-parallel-jobs"
+	pod.Container.Image = pj.image(p.CypressDockerVersion)
+	pod.ImagePullSecrets = append(commons.GetKubernetesJobsImagePullSecrets(), commons.SplitList(pj.Image_pull_secrets)...)
+	pod.Container.Resources = pj.resources()
+	pod.Placement = pj.placement()
+	return pod, nil
+}
+
+// sshKnownHosts return SSH known hosts of the project
+// or the cluster wide default ones when not set
+func (pj *projects) sshKnownHosts() string {
+	if pj.Ssh_known_hosts != "" {
+		return pj.Ssh_known_hosts
+	}
+	return commons.GetGitSSHKnownHosts()
+}
+
+// applySecret create or update the secret holding git credentials of the project
+// in the jobs namespace or delete it when the project has no credentials anymore
+func (pj *projects) applySecret(clientset k8s.Interface) (err error) {
+	name := kubernetes.ProjectSecretName(pj.Project_id)
+	if pj.Username == "" && pj.Password == "" && pj.Ssh_private_key == "" {
+		err = kubernetes.DeleteSecret(clientset, commons.GetKubernetesJobsNamespace(), name)
+		if err != nil && k8serrors.IsNotFound(err) {
+			return nil
 		}
+		return
+	}
+	return kubernetes.ApplySecret(
+		clientset,
+		commons.GetKubernetesJobsNamespace(),
+		name,
+		commonLabels,
+		map[string]string{
+			"username":       pj.Username,
+			"password":       pj.Password,
+			"ssh-privatekey": pj.Ssh_private_key,
+			"known_hosts":    pj.sshKnownHosts(),
+		},
+	)
+}
+
+// image return the image of the project or the image repository of the project
+// or the cluster wide default one tagged with cypress docker version provided
+func (pj *projects) image(cypressDockerVersion string) string {
+	if pj.Image != "" {
+		return pj.Image
+	}
+	if pj.Image_repository != "" {
+		return fmt.Sprintf("%s:%s", pj.Image_repository, cypressDockerVersion)
+	}
+	return fmt.Sprintf("%s:%s", commons.GetKubernetesJobsImageRepository(), cypressDockerVersion)
+}
+
+// placement return node selector, tolerations and affinity of the project
+// or the cluster wide defaults when not set
+func (pj *projects) placement() (p models.Placement) {
+	p.NodeSelector = pj.Node_selector
+	if p.NodeSelector == "" {
+		p.NodeSelector = commons.GetKubernetesJobsNodeSelector()
+	}
+	p.Tolerations = pj.Tolerations
+	if p.Tolerations == "" {
+		p.Tolerations = commons.GetKubernetesJobsTolerations()
+	}
+	p.Affinity = pj.Affinity
+	if p.Affinity == "" {
+		p.Affinity = commons.GetKubernetesJobsAffinity()
+	}
+	return
+}
+
+// resources return CPU and memory requests and limits of the project.
+// Cluster wide defaults are used for CPU or memory when neither
+// request nor limit are set so they can't conflict with the project ones
+func (pj *projects) resources() (r models.Resources) {
+	r.CPURequest = pj.Cpu_request
+	r.CPULimit = pj.Cpu_limit
+	if r.CPURequest == "" && r.CPULimit == "" {
+		r.CPURequest = commons.GetKubernetesJobsCPURequest()
+		r.CPULimit = commons.GetKubernetesJobsCPULimit()
 	}
-	c.JSON(http.StatusCreated, "OK")
+	r.MemoryRequest = pj.Memory_request
+	r.MemoryLimit = pj.Memory_limit
+	if r.MemoryRequest == "" && r.MemoryLimit == "" {
+		r.MemoryRequest = commons.GetKubernetesJobsMemoryRequest()
+		r.MemoryLimit = commons.GetKubernetesJobsMemoryLimit()
+	}
+	return
+}
+
+// job return the job running the pod provided within the project timeout
+func (pj *projects) job(pod models.Pods) (job models.Jobs, err error) {
+	timeout, err := strconv.Atoi(pj.Timeout)
+	if err != nil {
+		return
+	}
+	return models.Jobs{
+		Pod:                     pod,
+		BackoffLimit:            commons.GetKubernetesJobsBackoffLimit(),
+		ActiveDeadlineSeconds:   int64(timeout*60) + int64(commons.GetReaperGracePeriod().Seconds()),
+		TTLSecondsAfterFinished: int32(commons.GetKubernetesJobsTTL().Seconds()),
+	}, nil
 }
 
-func Queued() {
+// run create the pod or the job running the specs according to the project kubernetes backend
+func (pj *projects) run(clientset k8s.Interface, pod models.Pods) (podName string, jobName string, err error) {
+	if pj.Kubernetes_backend != "job" {
+		podName, err = kubernetes.CreatePod(clientset, pod)
+		return
+	}
+
+	job, err := pj.job(pod)
+	if err != nil {
+		return
+	}
+	jobName, err = kubernetes.CreateJob(clientset, job)
+	return
+}
+
+// manifest return the pod or the job run would create according to the project kubernetes backend
+func (pj *projects) manifest(pod models.Pods) (z interface{}, err error) {
+	if pj.Kubernetes_backend != "job" {
+		return kubernetes.Pod(pod)
+	}
+
+	job, err := pj.job(pod)
+	if err != nil {
+		return
+	}
+	return kubernetes.Job(job)
+}
+
+// Queued start pods of QUEUED executions when their run has room left for them
+// and wait for them to be created with the kubernetes client provided
+func Queued(clientset k8s.Interface) {
 	log.Debug().Msg("Checking QUEUED execution list")
 	status, err := executionStatus("RUNNING")
 	if err != nil {
@@ -419,9 +966,10 @@ func Queued() {
 			wg.Add(1)
 			go func(run map[string]interface{}) {
 				defer wg.Done()
-				queuing(run)
+				queuing(clientset, run)
 			}(run)
 		}
+		wg.Wait()
 	} else {
 		status, err := executionStatus("QUEUED")
 		if err != nil {
@@ -435,26 +983,21 @@ func Queued() {
 				wg.Add(1)
 				go func(run map[string]interface{}) {
 					defer wg.Done()
-					queuing(run)
+					queuing(clientset, run)
 				}(run)
 			}
+			wg.Wait()
 		}
 	}
 }
 
-func queuing(run map[string]interface{}) {
+func queuing(clientset k8s.Interface, run map[string]interface{}) {
 	var (
 		p           plain
-		pj          projects
 		resultQueue []executionQueue
 	)
 
-	clientset, err := kubernetes.Client()
-	if err != nil {
-		log.Error().Err(err).Msg("Error occured while initializing kubernetes client")
-		return
-	}
-	err = kubernetes.GetNamespace(clientset, commons.GetKubernetesJobsNamespace())
+	err := kubernetes.GetNamespace(clientset, commons.GetKubernetesJobsNamespace())
 	if err != nil {
 		log.Warn().Err(err).Msg("Error occured while getting kubernetes namespace")
 		err = kubernetes.CreateNamespace(clientset, commons.GetKubernetesJobsNamespace())
@@ -466,12 +1009,17 @@ func queuing(run map[string]interface{}) {
 	err = kubernetes.GetServiceAccountName(clientset, commons.GetKubernetesJobsNamespace(), commons.GetKubernetesJobsNamespace())
 	if err != nil {
 		log.Warn().Err(err).Msgf("Error occured while getting kubernetes service account %s", commons.GetKubernetesJobsNamespace())
-		_, err = kubernetes.CreateServiceAccountName(clientset, commons.GetKubernetesJobsNamespace(), commons.GetKubernetesJobsNamespace())
+		_, err = kubernetes.CreateServiceAccountName(clientset, commons.GetKubernetesJobsNamespace(), commons.GetKubernetesJobsNamespace(), commons.GetKubernetesJobsImagePullSecrets()...)
 		if err != nil {
 			log.Error().Err(err).Msgf("Error occured while creating kubernetes service account %s", commons.GetKubernetesJobsNamespace())
 			return
 		}
 	}
+	err = kubernetes.SetServiceAccountImagePullSecrets(clientset, commons.GetKubernetesJobsNamespace(), commons.GetKubernetesJobsNamespace(), commons.GetKubernetesJobsImagePullSecrets()...)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error occured while setting image pull secrets of kubernetes service account %s", commons.GetKubernetesJobsNamespace())
+		return
+	}
 
 	uniqID := fmt.Sprintf("%s", run["uniq_id"])
 	countExecution, err := countExecutions(uniqID)
@@ -489,13 +1037,9 @@ func queuing(run map[string]interface{}) {
 	log.Debug().Msgf("queued %s length length %d", uniqID, len(queued))
 	if len(queued) > 0 {
 		var (
-			tmpSecs   []string
+			nextSpecs []string
 			finalSecs []string
-			nbSpec    int
-			reset     bool
 			pdn       updatePodName
-			command   []string
-			pod       models.Pods
 		)
 		err := mapstructure.Decode(queued, &resultQueue)
 		if err != nil {
@@ -504,35 +1048,39 @@ func queuing(run map[string]interface{}) {
 		}
 		p.ProjectName = resultQueue[0].Project_name
 		p.Branch = resultQueue[0].Branch
-
-		for k, v := range resultQueue {
-			tmpSecs = append(tmpSecs, v.Spec)
-			if (nbSpec + 1) == commons.GetMaxSpecs() {
-				finalSecs = append(finalSecs, strings.Join(tmpSecs, ","))
-				tmpSecs = nil
-				nbSpec = 0
-				reset = true
-			}
-			if !reset {
-				nbSpec++
+		p.Browser = resultQueue[0].Browser
+		p.ConfigFile = resultQueue[0].Config_file
+		p.CypressDockerVersion = resultQueue[0].Cypress_docker_version
+		// runs keep the max pods resolved at launch, project ones are used for runs created before
+		if resultQueue[0].Max_pods != "" {
+			p.MaxPods, err = strconv.Atoi(resultQueue[0].Max_pods)
+			if err != nil {
+				log.Error().Err(err).Msg("Error occured while converting string to int")
+				return
 			}
-			if nbSpec == 0 {
-				reset = false
+		}
+
+		// executions of the first queued shard run in the same pod while
+		// executions created before shards were recorded are chunked again
+		if resultQueue[0].Shard != "" {
+			for _, v := range resultQueue {
+				if v.Shard == resultQueue[0].Shard {
+					nextSpecs = append(nextSpecs, v.Spec)
+				}
 			}
-			if k == len(resultQueue)-1 && commons.GetMaxSpecs()%2 == 1 {
-				finalSecs = append(finalSecs, strings.Join(tmpSecs, ","))
+		} else {
+			var specs []string
+			for _, v := range resultQueue {
+				specs = append(specs, v.Spec)
 			}
+			nextSpecs = chunk(specs, commons.GetMaxSpecs())[0]
 		}
+		finalSecs = append(finalSecs, strings.Join(nextSpecs, ","))
 		log.Debug().Msgf("queued %s finalSecs %s", uniqID, finalSecs)
 
-		result, err := p.getProjectInfos()
+		pj, _, err := p.project()
 		if err != nil {
-			log.Error().Err(err).Msg("Error occured while performing db query")
-			return
-		}
-		err = mapstructure.Decode(result, &pj)
-		if err != nil {
-			log.Error().Err(err).Msg("Error occured while decoding structure")
+			log.Error().Err(err).Msg("Error occured while retrieving project")
 			return
 		}
 
@@ -541,108 +1089,47 @@ func queuing(run map[string]interface{}) {
 			log.Error().Err(err).Msg("Error occured while converting string to int")
 			return
 		}
-		count = int(math.Floor(float64(count) / float64(commons.GetMaxSpecs())))
-
-		maxPods, err := strconv.Atoi(pj.Max_pods)
+		projectID, err := strconv.Atoi(pj.Project_id)
 		if err != nil {
 			log.Error().Err(err).Msg("Error occured while converting string to int")
 			return
 		}
 
-		log.Debug().Msgf("queued %s running pods count %d VS max pods %d", uniqID, count, maxPods)
-		if count < maxPods {
-			annotations, err := pj.getProjectAnnotations()
+		log.Debug().Msgf("queued %s running pods count %d VS max pods %d", uniqID, count, p.MaxPods)
+		if count < p.MaxPods {
+			err = pj.applySecret(clientset)
 			if err != nil {
-				log.Error().Err(err).Msg("Error occured while performing db query")
+				log.Error().Err(err).Msgf("Error occured while applying kubernetes secret of project %s", pj.Project_id)
 				return
 			}
-			if len(annotations) > 0 {
-				annotation := make(map[string]string)
-				for _, k := range annotations {
-					annotation[fmt.Sprintf("%s", k["key"])] = fmt.Sprintf("%s", k["value"])
-				}
-				pod.Annotations = annotation
-			}
 
-			envVars, err := pj.getProjectEnvironments()
+			pod, err := pj.pod(p, uniqID, p.Branch, resultQueue[0].Commit_sha, finalSecs[0])
 			if err != nil {
 				log.Error().Err(err).Msg("Error occured while performing db query")
 				return
 			}
-			if len(envVars) > 0 {
-				var (
-					envs   []models.EnvironmentVar
-					envVar models.EnvironmentVar
-				)
-				for _, k := range envVars {
-					envVar.Key = fmt.Sprintf("CYPRESS_%s", k["key"])
-					envVar.Value = fmt.Sprintf("%s", k["value"])
-					envs = append(envs, envVar)
-				}
-				if strings.TrimSpace(os.Getenv("CYPRESS_PARALLEL_CLI_LOG_LEVEL")) != "" {
-					envVar.Key = "CYPRESS_PARALLEL_CLI_LOG_LEVEL"
-					envVar.Value = strings.TrimSpace(os.Getenv("CYPRESS_PARALLEL_CLI_LOG_LEVEL"))
-					envs = append(envs, envVar)
-				}
-				envVar.Key = "NO_COLOR"
-				envVar.Value = "1"
-				envs = append(envs, envVar)
-				pod.Container.EnvironmentVars = envs
-			}
-			pod.Namespace = commons.GetKubernetesJobsNamespace()
-			pod.GenerateName = "cypress-parallel-jobs-"
-			pod.Labels = commonLabels
-
-			command = append(command, "cypress-parallel-cli")
-			command = append(command, "cypress")
-			command = append(command, "--browser")
-			command = append(command, pj.Browser)
-			command = append(command, "--config-file")
-			command = append(command, pj.Config_file)
-			command = append(command, "--specs")
-			command = append(command, finalSecs[0])
-			command = append(command, "--uid")
-			command = append(command, uniqID)
-			command = append(command, "--branch")
-			command = append(command, p.Branch)
-			command = append(command, "--repository")
-			command = append(command, pj.Repository)
-			command = append(command, "--api-url")
-			command = append(command, commons.GetAPIUrl())
-			command = append(command, "--report-back")
-			command = append(command, "--timeout")
-			command = append(command, pj.Timeout)
-			if pj.Username != "" {
-				command = append(command, "--username")
-				command = append(command, pj.Username)
-			}
-			if pj.Password != "" {
-				command = append(command, "--password")
-				command = append(command, pj.Password)
-			}
-			tag := pj.Cypress_docker_version
 
-			pod.Container.Command = command
-			pod.Container.Name = "cypress-parallel-jobs"
-			pod.Container.Image = fmt.Sprintf("%s:%s", ghr, tag)
-
-			podName, err := kubernetes.CreatePod(clientset, pod)
+			podName, jobName, err := pj.run(clientset, pod)
 			if err != nil {
 				log.Error().Err(err).Msg("Error occured while creating pod")
 				return
 			}
-			log.Debug().Msgf("Pod name %s created for specs %s", podName, finalSecs[0])
+			log.Debug().Msgf("Pod name %s job name %s created for specs %s", podName, jobName, finalSecs[0])
 
 			for _, splittedSpec := range strings.Split(finalSecs[0], ",") {
 				pdn.podName = podName
+				pdn.jobName = jobName
 				pdn.uniqID = uniqID
 				pdn.spec = splittedSpec
 
-				err = pdn.update()
+				updated, err := pdn.update()
 				if err != nil {
 					log.Error().Err(err).Msg("Error occured while performing update db query")
 					return
 				}
+				if updated {
+					pdn.publish(projectID)
+				}
 			}
 		}
 	}
@@ -0,0 +1,143 @@
+// Package hooks will manage all hooks requirements
+package hooks
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/Lord-Y/cypress-parallel-api/commons"
+	"github.com/Lord-Y/cypress-parallel-api/kubernetes"
+	"github.com/Lord-Y/cypress-parallel-api/models"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestProjectsResources(t *testing.T) {
+	assert := assert.New(t)
+
+	os.Setenv("CYPRESS_PARALLEL_API_JOBS_MEMORY_LIMIT", "4Gi")
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_JOBS_MEMORY_LIMIT")
+
+	pj := projects{}
+	assert.Equal(models.Resources{CPURequest: "500m", MemoryRequest: "1Gi", MemoryLimit: "4Gi"}, pj.resources())
+
+	pj = projects{
+		Cpu_limit:      "250m",
+		Memory_request: "512Mi",
+	}
+	assert.Equal(models.Resources{CPULimit: "250m", MemoryRequest: "512Mi"}, pj.resources())
+}
+
+func TestProjectsPlacement(t *testing.T) {
+	assert := assert.New(t)
+
+	os.Setenv("CYPRESS_PARALLEL_API_JOBS_NODE_SELECTOR", `{"pool": "default"}`)
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_JOBS_NODE_SELECTOR")
+
+	pj := projects{}
+	assert.Equal(models.Placement{NodeSelector: `{"pool": "default"}`}, pj.placement())
+
+	pj = projects{
+		Node_selector: `{"pool": "browsers"}`,
+		Tolerations:   `[{"key": "browsers", "operator": "Exists"}]`,
+	}
+	assert.Equal(models.Placement{NodeSelector: `{"pool": "browsers"}`, Tolerations: `[{"key": "browsers", "operator": "Exists"}]`}, pj.placement())
+}
+
+func TestProjectsImage(t *testing.T) {
+	assert := assert.New(t)
+
+	pj := projects{}
+	assert.Equal("docker.pkg.github.com/lord-y/cypress-parallel-docker-images/cypress-parallel-docker-images:7.2.0-0.0.5", pj.image("7.2.0-0.0.5"))
+
+	os.Setenv("CYPRESS_PARALLEL_API_JOBS_IMAGE_REPOSITORY", "registry.example.com/cypress")
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_JOBS_IMAGE_REPOSITORY")
+	assert.Equal("registry.example.com/cypress:7.2.0-0.0.5", pj.image("7.2.0-0.0.5"))
+
+	pj.Image_repository = "mirror.example.com/cypress"
+	assert.Equal("mirror.example.com/cypress:7.2.0-0.0.5", pj.image("7.2.0-0.0.5"))
+
+	pj.Image = "mirror.example.com/custom:latest"
+	assert.Equal("mirror.example.com/custom:latest", pj.image("7.2.0-0.0.5"))
+}
+
+func TestProjectsSSHKnownHosts(t *testing.T) {
+	assert := assert.New(t)
+
+	pj := projects{}
+	assert.Empty(pj.sshKnownHosts())
+
+	os.Setenv("CYPRESS_PARALLEL_API_GIT_SSH_KNOWN_HOSTS", "github.com ssh-ed25519 default")
+	defer os.Unsetenv("CYPRESS_PARALLEL_API_GIT_SSH_KNOWN_HOSTS")
+	assert.Equal("github.com ssh-ed25519 default", pj.sshKnownHosts())
+
+	pj.Ssh_known_hosts = "github.com ssh-ed25519 project"
+	assert.Equal("github.com ssh-ed25519 project", pj.sshKnownHosts())
+}
+
+func TestProjectsApplySecret(t *testing.T) {
+	assert := assert.New(t)
+
+	client := k8sfake.NewSimpleClientset()
+	pj := projects{
+		Project_id:      "1",
+		Ssh_private_key: "key",
+		Ssh_known_hosts: "github.com ssh-ed25519 project",
+	}
+	namespace := commons.GetKubernetesJobsNamespace()
+	name := kubernetes.ProjectSecretName(pj.Project_id)
+
+	assert.NoError(pj.applySecret(client))
+	secret, err := client.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal("key", secret.StringData["ssh-privatekey"])
+	assert.Equal("github.com ssh-ed25519 project", secret.StringData["known_hosts"])
+
+	// no credentials anymore, secret must be deleted
+	pj = projects{Project_id: "1"}
+	assert.NoError(pj.applySecret(client))
+	_, err = client.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	assert.Error(err)
+	assert.NoError(pj.applySecret(client))
+}
+
+func TestPlainShardStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	p := plain{MaxPods: 2}
+	assert.Equal("NOT_STARTED", p.shardStatus(0))
+	assert.Equal("NOT_STARTED", p.shardStatus(1))
+	assert.Equal("QUEUED", p.shardStatus(2))
+}
+
+func TestProjectsManifest(t *testing.T) {
+	assert := assert.New(t)
+
+	pod := models.Pods{
+		GenerateName: "cypress-parallel-jobs-",
+		Namespace:    commons.GetKubernetesJobsNamespace(),
+	}
+	pod.Container.Name = "cypress-parallel-jobs"
+
+	pj := projects{Timeout: "10"}
+	z, err := pj.manifest(pod)
+	assert.NoError(err)
+	assert.IsType(&v1.Pod{}, z)
+
+	pj.Kubernetes_backend = "job"
+	z, err = pj.manifest(pod)
+	assert.NoError(err)
+	if assert.IsType(&batchv1.Job{}, z) {
+		assert.NotZero(*z.(*batchv1.Job).Spec.ActiveDeadlineSeconds)
+	}
+
+	pj.Timeout = "fake"
+	_, err = pj.manifest(pod)
+	assert.Error(err)
+}
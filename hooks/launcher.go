@@ -0,0 +1,140 @@
+// Package hooks will manage all hooks requirements
+package hooks
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Lord-Y/cypress-parallel-api/commons"
+	"github.com/Lord-Y/cypress-parallel-api/events"
+	"github.com/rs/zerolog/log"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+// launchQueueSize is the number of runs waiting for a launch worker.
+// Runs which don't fit in are picked up later by Pending
+const launchQueueSize = 1000
+
+// launchRequest is a run waiting for a launch worker
+type launchRequest struct {
+	uniqID    string
+	clientset k8s.Interface // kubernetes client used to create pods of the run
+}
+
+var (
+	launchQueue   = make(chan launchRequest, launchQueueSize)
+	launchWorkers sync.Once
+)
+
+// enqueue hand the PENDING run of the uniq id provided to launch workers
+// which are started on first use and will create its pods with the kubernetes client provided
+func enqueue(uniqID string, clientset k8s.Interface) {
+	launchWorkers.Do(func() {
+		for i := 0; i < commons.GetLaunchWorkers(); i++ {
+			go launchWorker()
+		}
+	})
+	select {
+	case launchQueue <- launchRequest{uniqID: uniqID, clientset: clientset}:
+	default:
+		log.Warn().Msgf("Launch queue is full, run %s will be launched later", uniqID)
+	}
+}
+
+// launchWorker launch runs of the queue one after the other
+func launchWorker() {
+	for r := range launchQueue {
+		launchRun(r.uniqID, r.clientset)
+	}
+}
+
+// launchRun claim the PENDING run of the uniq id provided and launch it with the kubernetes client provided.
+// Nothing is done when the run was already claimed by another worker or api instance
+func launchRun(uniqID string, clientset k8s.Interface) {
+	p, projectID, claimed, err := claimRun(uniqID)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing update db query")
+		return
+	}
+	if !claimed {
+		return
+	}
+	events.Publish(events.Event{
+		Type:      "run",
+		UniqID:    uniqID,
+		ProjectID: projectID,
+		Status:    "LAUNCHING",
+	})
+	p.clientset = clientset
+	log.Info().Msgf("Launching run %s of project %s", uniqID, p.ProjectName)
+	statusCode, err := p.execute(uniqID)
+	launched(uniqID, statusCode, err)
+}
+
+// launched record the status of the run of the uniq id provided once its launch is over.
+// Only errors of the caller are recorded as reason as others may leak internal details
+func launched(uniqID string, statusCode int, err error) {
+	var (
+		status = "LAUNCHED"
+		reason string
+	)
+	if err != nil {
+		status = "FAILED"
+		reason = "Internal Server Error"
+		if statusCode == http.StatusBadRequest {
+			reason = err.Error()
+		}
+		log.Error().Err(err).Msgf("Error occured while launching run %s", uniqID)
+	}
+	projectID, err := updateRunStatus(uniqID, status, reason)
+	if err == sql.ErrNoRows {
+		log.Info().Msgf("Run %s was cancelled while launching", uniqID)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing update db query")
+		return
+	}
+	events.Publish(events.Event{
+		Type:      "run",
+		UniqID:    uniqID,
+		ProjectID: projectID,
+		Status:    status,
+		Reason:    reason,
+	})
+}
+
+// Pending hand to launch workers runs still PENDING after a minute
+// like the ones lost when the api restarted or which did not fit in the launch queue.
+// Runs still LAUNCHING after the launch timeout were lost in the middle of their launch
+// and are marked as FAILED as some of their pods may already exist
+func Pending(clientset k8s.Interface) {
+	reason := fmt.Sprintf("Launch not over after %s, the api may have restarted meanwhile", commons.GetLaunchTimeout())
+	stale, err := failStaleLaunchingRuns(commons.GetLaunchTimeout(), reason)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing update db query")
+	}
+	for uniqID, projectID := range stale {
+		log.Warn().Msgf("Run %s is still launching after %s so let's mark it as failed", uniqID, commons.GetLaunchTimeout())
+		events.Publish(events.Event{
+			Type:      "run",
+			UniqID:    uniqID,
+			ProjectID: projectID,
+			Status:    "FAILED",
+			Reason:    reason,
+		})
+	}
+
+	pending, err := getPendingRuns(time.Minute)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		return
+	}
+	for _, uniqID := range pending {
+		log.Debug().Msgf("Run %s is still pending", uniqID)
+		enqueue(uniqID, clientset)
+	}
+}
@@ -3,8 +3,10 @@ package hooks
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/Lord-Y/cypress-parallel-api/commons"
+	"github.com/Lord-Y/cypress-parallel-api/events"
 	_ "github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 	"github.com/syyongx/php2go"
@@ -80,7 +82,7 @@ func (p *execution) create() (z int64, err error) {
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare("INSERT INTO executions(project_id, branch, execution_status, uniq_id, spec, result) VALUES($1, $2, $3, $4, $5, $6) RETURNING execution_id")
+	stmt, err := db.Prepare("INSERT INTO executions(project_id, branch, execution_status, uniq_id, spec, result, browser, config_file, cypress_docker_version, parent_uniq_id, shard, run_id) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, (SELECT run_id FROM runs WHERE uniq_id = $4)) RETURNING execution_id")
 	if err != nil && err != sql.ErrNoRows {
 		return z, err
 	}
@@ -92,6 +94,11 @@ func (p *execution) create() (z int64, err error) {
 		php2go.Addslashes(p.uniqID),
 		php2go.Addslashes(p.spec),
 		php2go.Addslashes(p.result),
+		php2go.Addslashes(p.browser),
+		php2go.Addslashes(p.configFile),
+		php2go.Addslashes(p.cypressDockerVersion),
+		php2go.Addslashes(p.parentUniqID),
+		p.shard,
 	).Scan(&z)
 	if err != nil && err != sql.ErrNoRows {
 		return z, err
@@ -217,32 +224,34 @@ func (p *projects) getProjectEnvironments() (z []map[string]interface{}, err err
 	return m, nil
 }
 
-// update will update pod_name field in DB
-func (p *updatePodName) update() (err error) {
+// update will update pod_name field in DB and return false when the execution was cancelled meanwhile
+func (p *updatePodName) update() (updated bool, err error) {
 	db, err := sql.Open(
 		"postgres",
 		commons.BuildDSN(),
 	)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to connect to DB")
-		return err
+		return false, err
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare("UPDATE executions SET pod_name = $1, execution_status = 'RUNNING' WHERE uniq_id = $2 AND spec = $3")
+	stmt, err := db.Prepare("UPDATE executions SET pod_name = $1, job_name = $2, execution_status = 'RUNNING', started_at = CURRENT_TIMESTAMP WHERE uniq_id = $3 AND spec = $4 AND execution_status <> 'CANCELLED'")
 	if err != nil && err != sql.ErrNoRows {
-		return err
+		return false, err
 	}
 	defer stmt.Close()
-	err = stmt.QueryRow(
+	result, err := stmt.Exec(
 		php2go.Addslashes(p.podName),
+		php2go.Addslashes(p.jobName),
 		php2go.Addslashes(p.uniqID),
 		php2go.Addslashes(p.spec),
-	).Scan()
-	if err != nil && err != sql.ErrNoRows {
-		return err
+	)
+	if err != nil {
+		return false, err
 	}
-	return nil
+	count, err := result.RowsAffected()
+	return count > 0, err
 }
 
 // executionStatus get executions by status
@@ -315,7 +324,7 @@ func pgqueued(uniqId string) (z []map[string]interface{}, err error) {
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare("SELECT e.*, p.project_name FROM executions e LEFT JOIN projects p ON e.project_id = p.project_id WHERE e.execution_status = 'QUEUED' AND e.uniq_id = $1")
+	stmt, err := db.Prepare("SELECT e.*, p.project_name, r.commit_sha, r.max_pods FROM executions e LEFT JOIN projects p ON e.project_id = p.project_id LEFT JOIN runs r ON e.run_id = r.run_id WHERE e.execution_status = 'QUEUED' AND e.uniq_id = $1 AND COALESCE(r.run_status, '') <> 'CANCELLED' AND NOT EXISTS (SELECT 1 FROM executions c WHERE c.uniq_id = e.uniq_id AND c.execution_status = 'CANCELLED') ORDER BY e.shard, e.execution_id")
 	if err != nil && err != sql.ErrNoRows {
 		return
 	}
@@ -363,7 +372,7 @@ func pgqueued(uniqId string) (z []map[string]interface{}, err error) {
 	return m, nil
 }
 
-// countExecutions will count number of executions not in specified values
+// countExecutions will count number of pods running executions of the uniq id provided
 func countExecutions(uniq_id string) (z map[string]string, err error) {
 	db, err := sql.Open(
 		"postgres",
@@ -375,7 +384,7 @@ func countExecutions(uniq_id string) (z map[string]string, err error) {
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare("SELECT COUNT(execution_id) FROM executions WHERE uniq_id = $1 AND execution_status = 'RUNNING'")
+	stmt, err := db.Prepare("SELECT COUNT(DISTINCT COALESCE(NULLIF(pod_name, ''), job_name)) FROM executions WHERE uniq_id = $1 AND execution_status = 'RUNNING'")
 	if err != nil && err != sql.ErrNoRows {
 		return z, err
 	}
@@ -420,3 +429,725 @@ func countExecutions(uniq_id string) (z map[string]string, err error) {
 	}
 	return m, nil
 }
+
+// getScheduledProjects get projects for which scheduling is enabled
+func getScheduledProjects() (z []map[string]interface{}, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("SELECT project_id, project_name, scheduling, scheduling_next_run FROM projects WHERE scheduling_enabled = true AND scheduling <> ''")
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	m := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		err = rows.Scan(scanArgs...)
+		if err != nil {
+			return
+		}
+		var value string
+		sub := make(map[string]interface{})
+		for i, col := range values {
+			if col == nil {
+				value = ""
+			} else {
+				value = php2go.Stripslashes(string(col))
+			}
+			sub[columns[i]] = value
+		}
+		m = append(m, sub)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return m, nil
+}
+
+// setSchedulingNextRun will define when the project must be launched
+func setSchedulingNextRun(projectID int, nextRun time.Time) (err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to connect to DB")
+		return err
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("UPDATE projects SET scheduling_next_run = $1 WHERE project_id = $2 AND scheduling_next_run IS NULL")
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	defer stmt.Close()
+	err = stmt.QueryRow(
+		nextRun,
+		projectID,
+	).Scan()
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	return nil
+}
+
+// claimScheduling will record last and next run of the project only if its next run is due.
+// It returns true when the caller is the one in charge of launching the project
+func claimScheduling(projectID int, now time.Time, nextRun time.Time) (claimed bool, err error) {
+	var z int64
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to connect to DB")
+		return false, err
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("UPDATE projects SET scheduling_last_run = $1, scheduling_next_run = $2 WHERE project_id = $3 AND scheduling_next_run <= $1 RETURNING project_id")
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	defer stmt.Close()
+	err = stmt.QueryRow(
+		now,
+		nextRun,
+		projectID,
+	).Scan(&z)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// getWebhookProjects get projects with their repository and webhook secret
+func getWebhookProjects() (z []map[string]interface{}, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("SELECT project_id, project_name, repository, branch, webhook_secret FROM projects")
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	m := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		err = rows.Scan(scanArgs...)
+		if err != nil {
+			return
+		}
+		var value string
+		sub := make(map[string]interface{})
+		for i, col := range values {
+			if col == nil {
+				value = ""
+			} else {
+				value = php2go.Stripslashes(string(col))
+			}
+			sub[columns[i]] = value
+		}
+		m = append(m, sub)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return m, nil
+}
+
+// getFailedExecutions get failed executions of the uniq id provided
+func getFailedExecutions(uniqID string) (z []map[string]interface{}, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("SELECT p.project_name, e.branch, e.spec, e.browser, e.config_file, e.cypress_docker_version, r.commit_sha FROM executions e LEFT JOIN projects p ON e.project_id = p.project_id LEFT JOIN runs r ON e.run_id = r.run_id WHERE e.execution_status = 'FAILED' AND e.uniq_id = $1 ORDER BY e.execution_id")
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(
+		php2go.Addslashes(uniqID),
+	)
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	m := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		err = rows.Scan(scanArgs...)
+		if err != nil {
+			return
+		}
+		var value string
+		sub := make(map[string]interface{})
+		for i, col := range values {
+			if col == nil {
+				value = ""
+			} else {
+				value = php2go.Stripslashes(string(col))
+			}
+			sub[columns[i]] = value
+		}
+		m = append(m, sub)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return m, nil
+}
+
+// failPod set executions of the pod provided as FAILED with reason as error output
+// and return the events of the executions updated
+func failPod(podName string, reason string) (z []events.Event, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("UPDATE executions SET execution_status = 'FAILED', execution_error_output = $1 WHERE pod_name = $2 AND execution_status IN ('NOT_STARTED', 'RUNNING') RETURNING uniq_id, project_id, spec, execution_status, COALESCE(pod_name, ''), COALESCE(job_name, '')")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(
+		php2go.Addslashes(reason),
+		php2go.Addslashes(podName),
+	)
+	if err != nil {
+		return
+	}
+	return executionEvents(rows, reason)
+}
+
+// getStuckExecutions get pods and jobs of executions running for longer than their project timeout
+// plus the grace period provided. Executions started before started_at was recorded
+// are timed from their creation date
+func getStuckExecutions(gracePeriod time.Duration) (z []map[string]interface{}, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("SELECT DISTINCT e.uniq_id, COALESCE(e.pod_name, '') pod_name, COALESCE(e.job_name, '') job_name, p.timeout FROM executions e LEFT JOIN projects p ON e.project_id = p.project_id WHERE e.execution_status = 'RUNNING' AND (COALESCE(e.pod_name, '') <> '' OR COALESCE(e.job_name, '') <> '') AND COALESCE(e.started_at, e.date) + p.timeout * INTERVAL '1 minute' + $1 * INTERVAL '1 second' < CURRENT_TIMESTAMP")
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(
+		int64(gracePeriod.Seconds()),
+	)
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	m := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		err = rows.Scan(scanArgs...)
+		if err != nil {
+			return
+		}
+		var value string
+		sub := make(map[string]interface{})
+		for i, col := range values {
+			if col == nil {
+				value = ""
+			} else {
+				value = php2go.Stripslashes(string(col))
+			}
+			sub[columns[i]] = value
+		}
+		m = append(m, sub)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return m, nil
+}
+
+// timeoutExecutions set running executions of the pod or job provided as FAILED with reason as error output
+// and return the events of the executions updated
+func timeoutExecutions(podName string, jobName string, reason string) (z []events.Event, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("UPDATE executions SET execution_status = 'FAILED', execution_error_output = $1 WHERE COALESCE(pod_name, '') = $2 AND COALESCE(job_name, '') = $3 AND execution_status = 'RUNNING' RETURNING uniq_id, project_id, spec, execution_status, COALESCE(pod_name, ''), COALESCE(job_name, '')")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(
+		php2go.Addslashes(reason),
+		php2go.Addslashes(podName),
+		php2go.Addslashes(jobName),
+	)
+	if err != nil {
+		return
+	}
+	return executionEvents(rows, reason)
+}
+
+// executionEvents return the execution events of the rows provided made of
+// uniq_id, project_id, spec, execution_status, pod_name and job_name with the reason provided
+func executionEvents(rows *sql.Rows, reason string) (z []events.Event, err error) {
+	defer rows.Close()
+	for rows.Next() {
+		e := events.Event{
+			Type:   "execution",
+			Reason: reason,
+		}
+		err = rows.Scan(&e.UniqID, &e.ProjectID, &e.Spec, &e.Status, &e.PodName, &e.JobName)
+		if err != nil {
+			return
+		}
+		for _, value := range []*string{&e.UniqID, &e.Spec, &e.Status, &e.PodName, &e.JobName} {
+			*value = php2go.Stripslashes(*value)
+		}
+		z = append(z, e)
+	}
+	return z, rows.Err()
+}
+
+// updateJobStatus set job status of executions of the job provided and when the job is over,
+// set its executions still not started or running as FAILED with reason as error output and return their events
+func updateJobStatus(jobName string, status string, reason string) (z []events.Event, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	// old is the execution before the update so only executions which status changed are returned
+	stmt, err := db.Prepare("UPDATE executions e SET job_status = $1, execution_status = CASE WHEN $1 IN ('SUCCEEDED', 'FAILED') AND e.execution_status IN ('NOT_STARTED', 'RUNNING') THEN 'FAILED' ELSE e.execution_status END, execution_error_output = CASE WHEN $1 IN ('SUCCEEDED', 'FAILED') AND e.execution_status IN ('NOT_STARTED', 'RUNNING') THEN $2 ELSE e.execution_error_output END FROM executions old WHERE old.execution_id = e.execution_id AND e.job_name = $3 AND e.job_status IS DISTINCT FROM $1 RETURNING e.uniq_id, e.project_id, e.spec, e.execution_status, COALESCE(e.pod_name, ''), COALESCE(e.job_name, ''), old.execution_status")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(
+		php2go.Addslashes(status),
+		php2go.Addslashes(reason),
+		php2go.Addslashes(jobName),
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var former string
+		e := events.Event{
+			Type:   "execution",
+			Reason: reason,
+		}
+		err = rows.Scan(&e.UniqID, &e.ProjectID, &e.Spec, &e.Status, &e.PodName, &e.JobName, &former)
+		if err != nil {
+			return
+		}
+		for _, value := range []*string{&e.UniqID, &e.Spec, &e.Status, &e.PodName, &e.JobName} {
+			*value = php2go.Stripslashes(*value)
+		}
+		if e.Status != former {
+			z = append(z, e)
+		}
+	}
+	return z, rows.Err()
+}
+
+// getSpecDurations get the average duration in seconds of each spec of the project provided
+// over the last 30 days. As specs of a pod run one after the other, the duration of a spec
+// is the time elapsed since the previous spec of the same pod finished or since the pod started
+func getSpecDurations(projectID string) (z []map[string]interface{}, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("SELECT spec, AVG(duration) duration FROM (SELECT spec, EXTRACT(EPOCH FROM finished_at - COALESCE(LAG(finished_at) OVER (PARTITION BY uniq_id, COALESCE(pod_name, ''), COALESCE(job_name, '') ORDER BY finished_at), started_at)) duration FROM executions WHERE project_id = $1 AND started_at IS NOT NULL AND finished_at IS NOT NULL AND date > CURRENT_TIMESTAMP - INTERVAL '30 days') d WHERE duration > 0 GROUP BY spec")
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(
+		php2go.Addslashes(projectID),
+	)
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	m := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		err = rows.Scan(scanArgs...)
+		if err != nil {
+			return
+		}
+		var value string
+		sub := make(map[string]interface{})
+		for i, col := range values {
+			if col == nil {
+				value = ""
+			} else {
+				value = php2go.Stripslashes(string(col))
+			}
+			sub[columns[i]] = value
+		}
+		m = append(m, sub)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return m, nil
+}
+
+// create will insert run in DB.
+// Runs created as LAUNCHING are started right away
+func (p *run) create() (err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to connect to DB")
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("INSERT INTO runs(uniq_id, project_id, run_status, branch, specs, browser, config_file, cypress_docker_version, max_pods, parent_uniq_id, triggered_by, requested_commit, started_at) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, CASE WHEN $3 = 'LAUNCHING' THEN CURRENT_TIMESTAMP END)")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		php2go.Addslashes(p.uniqID),
+		p.projectID,
+		php2go.Addslashes(p.runStatus),
+		php2go.Addslashes(p.branch),
+		php2go.Addslashes(p.specs),
+		php2go.Addslashes(p.browser),
+		php2go.Addslashes(p.configFile),
+		php2go.Addslashes(p.cypressDockerVersion),
+		p.maxPods,
+		php2go.Addslashes(p.parentUniqID),
+		php2go.Addslashes(p.triggeredBy),
+		php2go.Addslashes(p.requestedCommit),
+	)
+	return
+}
+
+// claimRun will mark the PENDING run of the uniq id provided as LAUNCHING and return its launch settings and project id.
+// claimed is false when the run is not PENDING anymore, meaning that someone else is in charge of it
+// or that it was cancelled
+func claimRun(uniqID string) (p plain, projectID int, claimed bool, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to connect to DB")
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("UPDATE runs r SET run_status = 'LAUNCHING', started_at = CURRENT_TIMESTAMP FROM projects p WHERE r.project_id = p.project_id AND r.uniq_id = $1 AND r.run_status = 'PENDING' RETURNING r.project_id, p.project_name, COALESCE(r.branch, ''), COALESCE(r.specs, ''), COALESCE(r.browser, ''), COALESCE(r.config_file, ''), COALESCE(r.cypress_docker_version, ''), COALESCE(r.max_pods, 0), COALESCE(r.parent_uniq_id, ''), COALESCE(r.requested_commit, '')")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRow(
+		php2go.Addslashes(uniqID),
+	).Scan(
+		&projectID,
+		&p.ProjectName,
+		&p.Branch,
+		&p.Specs,
+		&p.Browser,
+		&p.ConfigFile,
+		&p.CypressDockerVersion,
+		&p.MaxPods,
+		&p.parentUniqID,
+		&p.Commit,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return p, projectID, false, nil
+		}
+		return
+	}
+	for _, value := range []*string{&p.ProjectName, &p.Branch, &p.Specs, &p.Browser, &p.ConfigFile, &p.CypressDockerVersion, &p.parentUniqID, &p.Commit} {
+		*value = php2go.Stripslashes(*value)
+	}
+	return p, projectID, true, nil
+}
+
+// updateRunStatus will update the status of the run of the uniq id provided
+// with the reason of the change if any and return its project id. FAILED runs are finished right away.
+// CANCELLED runs are left as is and sql.ErrNoRows is returned
+func updateRunStatus(uniqID string, status string, reason string) (projectID int, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to connect to DB")
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("UPDATE runs SET run_status = $1, reason = $2, finished_at = CASE WHEN $1 = 'FAILED' THEN CURRENT_TIMESTAMP END WHERE uniq_id = $3 AND run_status <> 'CANCELLED' RETURNING project_id")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	err = stmt.QueryRow(
+		php2go.Addslashes(status),
+		php2go.Addslashes(reason),
+		php2go.Addslashes(uniqID),
+	).Scan(&projectID)
+	return
+}
+
+// getRunStatus will return the status of the run of the uniq id provided
+func getRunStatus(uniqID string) (status string, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to connect to DB")
+		return
+	}
+	defer db.Close()
+
+	err = db.QueryRow(
+		"SELECT run_status FROM runs WHERE uniq_id = $1",
+		php2go.Addslashes(uniqID),
+	).Scan(&status)
+	return
+}
+
+// updateRunCommit will record the commit tested by the run of the uniq id provided
+func updateRunCommit(uniqID string, commit string) (err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to connect to DB")
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("UPDATE runs SET commit_sha = $1 WHERE uniq_id = $2")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		php2go.Addslashes(commit),
+		php2go.Addslashes(uniqID),
+	)
+	return
+}
+
+// failStaleLaunchingRuns will mark as FAILED with the reason provided runs still LAUNCHING
+// after the duration provided and return their project id by uniq id
+func failStaleLaunchingRuns(age time.Duration, reason string) (z map[string]int, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to connect to DB")
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("UPDATE runs SET run_status = 'FAILED', reason = $1, finished_at = CURRENT_TIMESTAMP WHERE run_status = 'LAUNCHING' AND COALESCE(started_at, date) < CURRENT_TIMESTAMP - $2 * INTERVAL '1 second' RETURNING uniq_id, project_id")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(
+		php2go.Addslashes(reason),
+		age.Seconds(),
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	z = make(map[string]int)
+	for rows.Next() {
+		var (
+			uniqID    string
+			projectID int
+		)
+		err = rows.Scan(&uniqID, &projectID)
+		if err != nil {
+			return
+		}
+		z[php2go.Stripslashes(uniqID)] = projectID
+	}
+	err = rows.Err()
+	return
+}
+
+// getPendingRuns get uniq ids of runs still PENDING after the duration provided
+func getPendingRuns(age time.Duration) (z []string, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to connect to DB")
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("SELECT uniq_id FROM runs WHERE run_status = 'PENDING' AND date < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second' ORDER BY date")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(
+		age.Seconds(),
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var uniqID string
+		err = rows.Scan(&uniqID)
+		if err != nil {
+			return
+		}
+		z = append(z, php2go.Stripslashes(uniqID))
+	}
+	err = rows.Err()
+	return
+}
+
+// CreatePendingRunForUnitTesting in only for unit testing purpose and will create a PENDING run
+// of the project provided without handing it to launch workers
+func CreatePendingRunForUnitTesting(projectName string) (uniqID string, err error) {
+	p := plain{ProjectName: projectName}
+	pj, _, err := p.project()
+	if err != nil {
+		return
+	}
+	return p.createRun(pj, "PENDING")
+}
@@ -0,0 +1,71 @@
+// Package hooks will manage all hooks requirements
+package hooks
+
+import (
+	"fmt"
+
+	"github.com/Lord-Y/cypress-parallel-api/commons"
+	"github.com/Lord-Y/cypress-parallel-api/events"
+	"github.com/Lord-Y/cypress-parallel-api/kubernetes"
+	"github.com/mitchellh/mapstructure"
+	"github.com/rs/zerolog/log"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+// stuckExecutions will be use to "mapstructure" data from db
+type stuckExecutions struct {
+	Uniq_id  string
+	Pod_name string
+	Job_name string
+	Timeout  string
+}
+
+// Reaper will mark as FAILED executions running for longer than their project timeout
+// plus the grace period and delete their pods or jobs with the kubernetes client provided so queued runs can move on
+func Reaper(clientset k8s.Interface) {
+	var (
+		resultStuck []stuckExecutions
+	)
+	gracePeriod := commons.GetReaperGracePeriod()
+	result, err := getStuckExecutions(gracePeriod)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		return
+	}
+	if len(result) == 0 {
+		return
+	}
+
+	err = mapstructure.Decode(result, &resultStuck)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while decoding stuck executions")
+		return
+	}
+
+	for _, stuck := range resultStuck {
+		reason := fmt.Sprintf("Timed out after %s minutes plus a grace period of %s", stuck.Timeout, gracePeriod)
+		failed, err := timeoutExecutions(stuck.Pod_name, stuck.Job_name, reason)
+		if err != nil {
+			log.Error().Err(err).Msg("Error occured while performing db query")
+			continue
+		}
+		for _, e := range failed {
+			events.Publish(e)
+		}
+
+		if stuck.Job_name != "" {
+			log.Warn().Msgf("Job %s of uniq id %s timed out, %d execution(s) marked as FAILED", stuck.Job_name, stuck.Uniq_id, len(failed))
+			err = kubernetes.DeleteJob(clientset, commons.GetKubernetesJobsNamespace(), stuck.Job_name)
+			if err != nil && !k8serrors.IsNotFound(err) {
+				log.Error().Err(err).Msgf("Error occured while trying to delete job name: %s", stuck.Job_name)
+			}
+			continue
+		}
+		log.Warn().Msgf("Pod %s of uniq id %s timed out, %d execution(s) marked as FAILED", stuck.Pod_name, stuck.Uniq_id, len(failed))
+		err = kubernetes.DeletePod(clientset, commons.GetKubernetesJobsNamespace(), stuck.Pod_name)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			log.Error().Err(err).Msgf("Error occured while trying to delete pod name: %s", stuck.Pod_name)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package hooks will manage all hooks requirements
+package hooks
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+// scheduledProjects will be use to "mapstructure" data from db
+type scheduledProjects struct {
+	Project_id          string
+	Project_name        string
+	Scheduling          string
+	Scheduling_next_run string
+}
+
+// Scheduling launch unit testing of projects for which scheduling is enabled
+// and the next run is due. It is safe to call it from multiple api replicas
+// as a project run is claimed in DB before being launched
+func Scheduling(clientset k8s.Interface) {
+	var (
+		resultProjects []scheduledProjects
+	)
+	log.Debug().Msg("Checking scheduled projects")
+	result, err := getScheduledProjects()
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		return
+	}
+
+	err = mapstructure.Decode(result, &resultProjects)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while decoding structure")
+		return
+	}
+
+	for _, project := range resultProjects {
+		schedule, err := cron.ParseStandard(project.Scheduling)
+		if err != nil {
+			log.Error().Err(err).Msgf("Error occured while parsing scheduling %s of project %s", project.Scheduling, project.Project_name)
+			continue
+		}
+		projectID, err := strconv.Atoi(project.Project_id)
+		if err != nil {
+			log.Error().Err(err).Msg("Error occured while converting string to int")
+			continue
+		}
+		now := time.Now()
+
+		// the project has never been scheduled or its scheduling has been updated
+		// so we only need to define when it will run
+		if project.Scheduling_next_run == "" {
+			err = setSchedulingNextRun(projectID, schedule.Next(now).UTC())
+			if err != nil {
+				log.Error().Err(err).Msg("Error occured while performing update db query")
+			}
+			continue
+		}
+
+		claimed, err := claimScheduling(projectID, now.UTC(), schedule.Next(now).UTC())
+		if err != nil {
+			log.Error().Err(err).Msg("Error occured while performing update db query")
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		p := plain{
+			ProjectName: project.Project_name,
+			TriggeredBy: "scheduling",
+			clientset:   clientset,
+		}
+		log.Info().Msgf("Launching scheduled unit testing of project %s", project.Project_name)
+
+		_, _, err = p.launch()
+		if err != nil {
+			log.Error().Err(err).Msgf("Error occured while launching scheduled unit testing of project %s", p.ProjectName)
+		}
+	}
+}
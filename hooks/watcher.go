@@ -0,0 +1,77 @@
+// Package hooks will manage all hooks requirements
+package hooks
+
+import (
+	"fmt"
+
+	"github.com/Lord-Y/cypress-parallel-api/commons"
+	"github.com/Lord-Y/cypress-parallel-api/events"
+	"github.com/Lord-Y/cypress-parallel-api/kubernetes"
+	"github.com/rs/zerolog/log"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+// Watch will watch cypress-parallel-jobs pods until stopCh is closed and mark
+// their executions as FAILED when a pod crashed, got evicted or cannot pull its image.
+// Without it, executions of a dead pod stay RUNNING forever and block queued runs.
+// Jobs are watched as well to track their status as the job controller retries failed pods itself
+func Watch(clientset k8s.Interface, stopCh <-chan struct{}) {
+	log.Info().Msg("Watching cypress-parallel-jobs pods and jobs")
+	go kubernetes.WatchJobs(
+		clientset,
+		commons.GetKubernetesJobsNamespace(),
+		fmt.Sprintf("app=%s", commonLabels["app"]),
+		jobUpdated,
+		stopCh,
+	)
+	kubernetes.WatchPods(
+		clientset,
+		commons.GetKubernetesJobsNamespace(),
+		fmt.Sprintf("app=%s", commonLabels["app"]),
+		func(podName string, reason string) {
+			podFailed(clientset, podName, reason)
+		},
+		stopCh,
+	)
+}
+
+// podFailed mark executions of the pod as FAILED and delete the pod
+// so it does not stay stuck in the namespace
+func podFailed(clientset k8s.Interface, podName string, reason string) {
+	failed, err := failPod(podName, reason)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		return
+	}
+	if len(failed) == 0 {
+		return
+	}
+	log.Warn().Msgf("Pod %s failed, %d execution(s) marked as FAILED: %s", podName, len(failed), reason)
+	for _, e := range failed {
+		events.Publish(e)
+	}
+
+	err = kubernetes.DeletePod(clientset, commons.GetKubernetesJobsNamespace(), podName)
+	if err != nil {
+		log.Error().Err(err).Msgf("Error occured while trying to delete pod name: %s", podName)
+	}
+}
+
+// jobUpdated record the job status on its executions which are marked as FAILED once the job
+// has exhausted its retries or exceeded its deadline, or once it succeeded without reporting their results
+func jobUpdated(jobName string, status string, reason string) {
+	if status == "SUCCEEDED" {
+		reason = fmt.Sprintf("Job %s succeeded without reporting the result of the spec", jobName)
+	}
+	failed, err := updateJobStatus(jobName, status, reason)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		return
+	}
+	for _, e := range failed {
+		events.Publish(e)
+	}
+	if status == "FAILED" {
+		log.Warn().Msgf("Job %s failed: %s", jobName, reason)
+	}
+}
@@ -0,0 +1,175 @@
+// Package hooks will manage all hooks requirements
+package hooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Lord-Y/cypress-parallel-api/encryption"
+	"github.com/Lord-Y/cypress-parallel-api/kubernetes"
+	"github.com/gin-gonic/gin"
+	"github.com/mitchellh/mapstructure"
+	"github.com/rs/zerolog/log"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+// webhookProjects will be use to "mapstructure" data from db
+type webhookProjects struct {
+	Project_id     string
+	Project_name   string
+	Repository     string
+	Branch         string
+	Webhook_secret string
+}
+
+// webhookEvent hold what is required to launch unit testing from a git forge event
+type webhookEvent struct {
+	forge        string   // Name of the git forge which sent the event
+	repositories []string // Urls of the repository that triggered the event
+	branch       string   // Branch to test
+	target       string   // Branch projects must be set to, the pushed one or the one pull and merge requests target
+	commit       string   // Commit that triggered the event
+}
+
+// normalizeRepository return a comparable form of a git repository url
+// so https://github.com/org/repo.git, git@github.com:org/repo.git and
+// ssh://git@github.com/org/repo will all return github.com/org/repo
+func normalizeRepository(repository string) string {
+	r := strings.ToLower(strings.TrimSpace(repository))
+	if u, err := url.Parse(r); err == nil && u.Host != "" {
+		r = u.Hostname() + u.Path
+	} else if i := strings.Index(r, "@"); i >= 0 {
+		r = strings.Replace(r[i+1:], ":", "/", 1)
+	}
+	r = strings.TrimSuffix(strings.TrimSuffix(r, "/"), ".git")
+	return r
+}
+
+// matches return true when the project repository is one of the event urls
+// and the project branch is the one targeted by the event
+func (w *webhookEvent) matches(project webhookProjects) bool {
+	if project.Branch != w.target {
+		return false
+	}
+	for _, repository := range w.repositories {
+		if repository != "" && normalizeRepository(project.Repository) == normalizeRepository(repository) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchingProjects return projects matching the event repository and branch
+func (w *webhookEvent) matchingProjects() (z []webhookProjects, err error) {
+	var (
+		resultProjects []webhookProjects
+	)
+	result, err := getWebhookProjects()
+	if err != nil {
+		return
+	}
+	err = mapstructure.Decode(result, &resultProjects)
+	if err != nil {
+		return
+	}
+
+	for _, project := range resultProjects {
+		if !w.matches(project) {
+			continue
+		}
+		project.Webhook_secret, err = encryption.Decrypt(project.Webhook_secret)
+		if err != nil {
+			return z, err
+		}
+		z = append(z, project)
+	}
+	return z, nil
+}
+
+// launch start unit testing of all projects provided in the background with the kubernetes client provided
+// so git forges get their answer as fast as possible
+func (w *webhookEvent) launch(clientset k8s.Interface, projects []webhookProjects) (z []string) {
+	for _, project := range projects {
+		p := plain{
+			ProjectName: project.Project_name,
+			Branch:      w.branch,
+			Commit:      w.commit,
+			TriggeredBy: w.forge,
+			clientset:   clientset,
+		}
+		log.Info().Msgf("Launching unit testing of project %s on branch %s for commit %s", project.Project_name, w.branch, w.commit)
+		_, _, err := p.launch()
+		if err != nil {
+			log.Error().Err(err).Msgf("Error occured while launching unit testing of project %s", p.ProjectName)
+			continue
+		}
+		z = append(z, project.Project_name)
+	}
+	return
+}
+
+// trigger launch unit testing of projects matching the event repository
+// for which verify func succeed with the project webhook secret
+func (w *webhookEvent) trigger(c *gin.Context, verify func(secret string) bool) {
+	projects, err := w.matchingProjects()
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	if len(projects) == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+		return
+	}
+
+	var verified []webhookProjects
+	for _, project := range projects {
+		if verify(project.Webhook_secret) {
+			verified = append(verified, project)
+		}
+	}
+	if len(verified) == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("Invalid signature for repository %s", w.repositories[0])})
+		return
+	}
+	clientset, err := kubernetes.ClientFrom(c)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while initializing kubernetes client")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"projects": w.launch(clientset, verified)})
+}
+
+// verifyToken check that token is equal to the secret provided
+func verifyToken(secret string, token string) bool {
+	if secret == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(token)) == 1
+}
+
+// isNullCommit return true when the commit is made of zeros which is what forges send on branch deletion
+func isNullCommit(commit string) bool {
+	return strings.Trim(commit, "0") == ""
+}
+
+// verifyHMACSHA256 check that signature is the hex encoded hmac sha256 of the payload with the secret provided
+func verifyHMACSHA256(secret string, signature string, payload []byte) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,103 @@
+// Package hooks will manage all hooks requirements
+package hooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeRepository(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		repository string
+		actual     string
+	}{
+		{
+			repository: "https://github.com/cypress-io/cypress-example-kitchensink.git",
+			actual:     "github.com/cypress-io/cypress-example-kitchensink",
+		},
+		{
+			repository: "https://github.com/Cypress-io/cypress-example-kitchensink/",
+			actual:     "github.com/cypress-io/cypress-example-kitchensink",
+		},
+		{
+			repository: "git@github.com:cypress-io/cypress-example-kitchensink.git",
+			actual:     "github.com/cypress-io/cypress-example-kitchensink",
+		},
+		{
+			repository: "ssh://git@gitlab.example.com:2222/cypress/kitchensink.git",
+			actual:     "gitlab.example.com/cypress/kitchensink",
+		},
+		{
+			repository: "",
+			actual:     "",
+		},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(tc.actual, normalizeRepository(tc.repository))
+	}
+}
+
+func TestMatches(t *testing.T) {
+	assert := assert.New(t)
+
+	project := webhookProjects{
+		Repository: "https://github.com/cypress-io/cypress-example-kitchensink.git",
+		Branch:     "master",
+	}
+	w := webhookEvent{
+		repositories: []string{"", "git@github.com:cypress-io/cypress-example-kitchensink.git"},
+		branch:       "master",
+		target:       "master",
+	}
+	assert.True(w.matches(project))
+
+	// pull requests match projects of the branch they target
+	w.branch = "feature"
+	assert.True(w.matches(project))
+
+	w.target = "feature"
+	assert.False(w.matches(project))
+
+	w.target = "master"
+	w.repositories = []string{"https://github.com/Lord-Y/unknown.git"}
+	assert.False(w.matches(project))
+}
+
+func TestVerifyHMACSHA256(t *testing.T) {
+	assert := assert.New(t)
+
+	payload := []byte(`{"ref":"refs/heads/master"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(payload)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	assert.True(verifyHMACSHA256("secret", signature, payload))
+	assert.False(verifyHMACSHA256("bad", signature, payload))
+	assert.False(verifyHMACSHA256("", signature, payload))
+	assert.False(verifyHMACSHA256("secret", "", payload))
+	assert.False(verifyHMACSHA256("secret", "not-hex", payload))
+}
+
+func TestVerifyToken(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(verifyToken("secret", "secret"))
+	assert.False(verifyToken("secret", "bad"))
+	assert.False(verifyToken("", ""))
+	assert.False(verifyToken("secret", ""))
+}
+
+func TestIsNullCommit(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(isNullCommit("0000000000000000000000000000000000000000"))
+	assert.True(isNullCommit(""))
+	assert.False(isNullCommit("6113728f27ae82c7b1a177c8d03f9e96e0adf246"))
+}
@@ -0,0 +1,27 @@
+// Package fakeclient provide the client-go fake clientset used in unit testing
+package fakeclient
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// New return client-go fake clientset which generate names
+// of objects created with generateName like the api server does
+func New() *k8sfake.Clientset {
+	client := k8sfake.NewSimpleClientset()
+	client.PrependReactor("create", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		object, err := meta.Accessor(action.(k8stesting.CreateAction).GetObject())
+		if err != nil {
+			return false, nil, err
+		}
+		if object.GetName() == "" && object.GetGenerateName() != "" {
+			object.SetName(object.GetGenerateName() + utilrand.String(5))
+		}
+		return false, nil, nil
+	})
+	return client
+}
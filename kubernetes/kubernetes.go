@@ -3,21 +3,54 @@ package kubernetes
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/Lord-Y/cypress-parallel-api/commons"
 	"github.com/Lord-Y/cypress-parallel-api/models"
+	"github.com/gin-gonic/gin"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
 )
 
+// contextKey is the key of the kubernetes client injected in gin context
+const contextKey = "kubernetesClient"
+
+// Inject return a middleware handing the client provided to handlers through ClientFrom
+// instead of connecting to a cluster like client-go fake clientset in unit testing
+func Inject(clientset kubernetes.Interface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(contextKey, clientset)
+		c.Next()
+	}
+}
+
+// ClientFrom return the kubernetes client injected in the gin context provided
+// or connect to the cluster with Client when none was injected
+func ClientFrom(c *gin.Context) (clientset kubernetes.Interface, err error) {
+	if v, ok := c.Get(contextKey); ok {
+		if clientset, ok = v.(kubernetes.Interface); ok {
+			return clientset, nil
+		}
+	}
+	return Client()
+}
+
 // Client return requirements to be able to connect to kubernetes cluster
 // with the program running inside or outside of the cluster
-func Client() (c *kubernetes.Clientset, err error) {
+func Client() (c kubernetes.Interface, err error) {
 	if commons.GetKubernetesMode() == "" {
 		config, err := rest.InClusterConfig()
 		if err != nil {
@@ -53,7 +86,7 @@ func Client() (c *kubernetes.Clientset, err error) {
 }
 
 // GetNamespace check if namespace defined for jobs exist or not
-func GetNamespace(clientset *kubernetes.Clientset, namespace string) (err error) {
+func GetNamespace(clientset kubernetes.Interface, namespace string) (err error) {
 	_, err = clientset.
 		CoreV1().
 		Namespaces().
@@ -66,7 +99,7 @@ func GetNamespace(clientset *kubernetes.Clientset, namespace string) (err error)
 }
 
 // CreateNamespace permit to create namespace
-func CreateNamespace(clientset *kubernetes.Clientset, namespace string) (err error) {
+func CreateNamespace(clientset kubernetes.Interface, namespace string) (err error) {
 	ns := &v1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: namespace,
@@ -84,7 +117,7 @@ func CreateNamespace(clientset *kubernetes.Clientset, namespace string) (err err
 }
 
 // DeleteNamespace permit to delete created namespace
-func DeleteNamespace(clientset *kubernetes.Clientset, namespace string) (err error) {
+func DeleteNamespace(clientset kubernetes.Interface, namespace string) (err error) {
 	err = clientset.
 		CoreV1().
 		Namespaces().
@@ -97,7 +130,7 @@ func DeleteNamespace(clientset *kubernetes.Clientset, namespace string) (err err
 }
 
 // GetServiceAccountName permit to get service account in specified namespace
-func GetServiceAccountName(clientset *kubernetes.Clientset, namespace string, serviceAccount string) (err error) {
+func GetServiceAccountName(clientset kubernetes.Interface, namespace string, serviceAccount string) (err error) {
 	_, err = clientset.
 		CoreV1().
 		ServiceAccounts(namespace).
@@ -110,12 +143,14 @@ func GetServiceAccountName(clientset *kubernetes.Clientset, namespace string, se
 }
 
 // CreateServiceAccountName permit to create service account that will be used while creating the pod
-func CreateServiceAccountName(clientset *kubernetes.Clientset, namespace string, serviceAccount string) (serviceAccountName string, err error) {
+// with the image pull secrets provided if any
+func CreateServiceAccountName(clientset kubernetes.Interface, namespace string, serviceAccount string, imagePullSecrets ...string) (serviceAccountName string, err error) {
 	sa := &v1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      serviceAccount,
 			Namespace: namespace,
 		},
+		ImagePullSecrets: localObjectReferences(imagePullSecrets),
 	}
 	result, err := clientset.
 		CoreV1().
@@ -131,62 +166,338 @@ func CreateServiceAccountName(clientset *kubernetes.Clientset, namespace string,
 	return result.Name, nil
 }
 
-// CreatePod permit to create pod inside of specified namespace
-func CreatePod(clientset *kubernetes.Clientset, m models.Pods) (podName string, err error) {
+// SetServiceAccountImagePullSecrets permit to add image pull secrets provided
+// to the existing service account when they are missing
+func SetServiceAccountImagePullSecrets(clientset kubernetes.Interface, namespace string, serviceAccount string, imagePullSecrets ...string) (err error) {
+	if len(imagePullSecrets) == 0 {
+		return
+	}
+	sa, err := clientset.
+		CoreV1().
+		ServiceAccounts(namespace).
+		Get(
+			context.TODO(),
+			serviceAccount,
+			metav1.GetOptions{},
+		)
+	if err != nil {
+		return
+	}
+
+	existing := make(map[string]bool)
+	for _, secret := range sa.ImagePullSecrets {
+		existing[secret.Name] = true
+	}
+	updated := false
+	for _, secret := range localObjectReferences(imagePullSecrets) {
+		if !existing[secret.Name] {
+			sa.ImagePullSecrets = append(sa.ImagePullSecrets, secret)
+			existing[secret.Name] = true
+			updated = true
+		}
+	}
+	if !updated {
+		return
+	}
+	_, err = clientset.
+		CoreV1().
+		ServiceAccounts(namespace).
+		Update(
+			context.TODO(),
+			sa,
+			metav1.UpdateOptions{},
+		)
+	return
+}
+
+// localObjectReferences return references of the non empty and distinct names provided
+func localObjectReferences(names []string) (references []v1.LocalObjectReference) {
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if name != "" && !seen[name] {
+			references = append(references, v1.LocalObjectReference{Name: name})
+			seen[name] = true
+		}
+	}
+	return
+}
+
+// ValidateImagePullSecrets permit to check that image pull secrets are valid kubernetes secret names
+func ValidateImagePullSecrets(imagePullSecrets []string) (err error) {
+	for _, name := range imagePullSecrets {
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return fmt.Errorf("Invalid image pull secret %s, error: %s", name, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// ProjectSecretName return the name of the secret holding git credentials of the project
+func ProjectSecretName(projectID string) string {
+	return fmt.Sprintf("cypress-parallel-project-%s", projectID)
+}
+
+// ApplySecret permit to create or update the opaque secret inside of specified namespace
+func ApplySecret(clientset kubernetes.Interface, namespace string, name string, labels map[string]string, data map[string]string) (err error) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Type:       v1.SecretTypeOpaque,
+		StringData: data,
+	}
+	_, err = clientset.
+		CoreV1().
+		Secrets(namespace).
+		Update(
+			context.TODO(),
+			secret,
+			metav1.UpdateOptions{},
+		)
+	if err == nil || !k8serrors.IsNotFound(err) {
+		return
+	}
+	_, err = clientset.
+		CoreV1().
+		Secrets(namespace).
+		Create(
+			context.TODO(),
+			secret,
+			metav1.CreateOptions{},
+		)
+	return
+}
+
+// DeleteSecret permit to delete secret inside of specified namespace
+func DeleteSecret(clientset kubernetes.Interface, namespace string, name string) (err error) {
+	err = clientset.
+		CoreV1().
+		Secrets(namespace).
+		Delete(
+			context.TODO(),
+			name,
+			metav1.DeleteOptions{},
+		)
+	return
+}
+
+// ValidateResources permit to check that resources are valid kubernetes quantities
+// and that requests do not exceed limits
+func ValidateResources(r models.Resources) (err error) {
+	_, err = resourceRequirements(r)
+	return
+}
+
+// resourceRequirements return the container resource requirements of resources provided
+func resourceRequirements(r models.Resources) (requirements v1.ResourceRequirements, err error) {
+	requests := v1.ResourceList{}
+	limits := v1.ResourceList{}
+	for _, k := range []struct {
+		name    v1.ResourceName
+		request string
+		limit   string
+		kind    string
+	}{
+		{v1.ResourceCPU, r.CPURequest, r.CPULimit, "cpu"},
+		{v1.ResourceMemory, r.MemoryRequest, r.MemoryLimit, "memory"},
+	} {
+		if k.request != "" {
+			q, err := resource.ParseQuantity(k.request)
+			if err != nil {
+				return requirements, fmt.Errorf("Invalid %s request %s, error: %s", k.kind, k.request, err.Error())
+			}
+			requests[k.name] = q
+		}
+		if k.limit != "" {
+			q, err := resource.ParseQuantity(k.limit)
+			if err != nil {
+				return requirements, fmt.Errorf("Invalid %s limit %s, error: %s", k.kind, k.limit, err.Error())
+			}
+			limits[k.name] = q
+		}
+		request, hasRequest := requests[k.name]
+		limit, hasLimit := limits[k.name]
+		if hasRequest && hasLimit && request.Cmp(limit) > 0 {
+			return requirements, fmt.Errorf("%s request %s must be less than or equal to %s limit %s", k.kind, k.request, k.kind, k.limit)
+		}
+	}
+	if len(requests) > 0 {
+		requirements.Requests = requests
+	}
+	if len(limits) > 0 {
+		requirements.Limits = limits
+	}
+	return requirements, nil
+}
+
+// ValidatePlacement permit to check that node selector, tolerations and affinity
+// are valid json representations of their kubernetes types
+func ValidatePlacement(p models.Placement) (err error) {
+	_, _, _, err = placement(p)
+	return
+}
+
+// placement return node selector, tolerations and affinity decoded from placement provided
+func placement(p models.Placement) (nodeSelector map[string]string, tolerations []v1.Toleration, affinity *v1.Affinity, err error) {
+	if p.NodeSelector != "" {
+		if err = json.Unmarshal([]byte(p.NodeSelector), &nodeSelector); err != nil {
+			return nil, nil, nil, fmt.Errorf("Invalid node selector, error: %s", err.Error())
+		}
+	}
+	if p.Tolerations != "" {
+		if err = json.Unmarshal([]byte(p.Tolerations), &tolerations); err != nil {
+			return nil, nil, nil, fmt.Errorf("Invalid tolerations, error: %s", err.Error())
+		}
+	}
+	if p.Affinity != "" {
+		affinity = &v1.Affinity{}
+		if err = json.Unmarshal([]byte(p.Affinity), affinity); err != nil {
+			return nil, nil, nil, fmt.Errorf("Invalid affinity, error: %s", err.Error())
+		}
+	}
+	return
+}
+
+// defaultAffinity return the affinity used when none is provided which
+// prefer to spread pods across nodes
+func defaultAffinity(namespace string) *v1.Affinity {
+	return &v1.Affinity{
+		PodAntiAffinity: &v1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []v1.WeightedPodAffinityTerm{
+				{
+					Weight: int32(5),
+					PodAffinityTerm: v1.PodAffinityTerm{
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{
+								"worker": "kubernetes",
+							},
+						},
+						Namespaces: []string{
+							namespace,
+						},
+						TopologyKey: "kubernetes.io/hostname",
+					},
+				},
+			},
+		},
+	}
+}
+
+// secretVolumes return kubernetes volumes and their container mounts
+// of the secrets provided
+func secretVolumes(m []models.SecretVolume) (volumes []v1.Volume, volumeMounts []v1.VolumeMount) {
+	mode := int32(0400)
+	for _, k := range m {
+		var items []v1.KeyToPath
+		for _, key := range k.Keys {
+			items = append(items, v1.KeyToPath{Key: key, Path: key})
+		}
+		volumes = append(volumes, v1.Volume{
+			Name: k.Name,
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName:  k.SecretName,
+					Items:       items,
+					DefaultMode: &mode,
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, v1.VolumeMount{
+			Name:      k.Name,
+			MountPath: k.MountPath,
+			ReadOnly:  true,
+		})
+	}
+	return
+}
+
+// podSpec return the pod specification shared by pods and jobs
+func podSpec(m models.Pods) (spec v1.PodSpec, err error) {
 	var (
 		env  v1.EnvVar
 		envs []v1.EnvVar
 	)
 	terminationGracePeriodSeconds := int64(300)
 
+	resources, err := resourceRequirements(m.Container.Resources)
+	if err != nil {
+		return
+	}
+	nodeSelector, tolerations, affinity, err := placement(m.Placement)
+	if err != nil {
+		return
+	}
+	if affinity == nil {
+		affinity = defaultAffinity(m.Namespace)
+	}
+
 	if len(m.Container.EnvironmentVars) > 0 {
 		for _, k := range m.Container.EnvironmentVars {
 			env.Name = k.Key
 			env.Value = k.Value
+			env.ValueFrom = nil
+			if k.SecretName != "" {
+				env.Value = ""
+				env.ValueFrom = &v1.EnvVarSource{
+					SecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: k.SecretName,
+						},
+						Key: k.SecretKey,
+					},
+				}
+			}
 			envs = append(envs, env)
 		}
 	}
-	pod := &v1.Pod{
+	volumes, volumeMounts := secretVolumes(m.Container.SecretVolumes)
+	return v1.PodSpec{
+		Containers: []v1.Container{
+			{
+				Name:            m.Container.Name,
+				Image:           m.Container.Image,
+				Command:         m.Container.Command,
+				Env:             envs,
+				ImagePullPolicy: v1.PullIfNotPresent,
+				Resources:       resources,
+				VolumeMounts:    volumeMounts,
+			},
+		},
+		Volumes:                       volumes,
+		RestartPolicy:                 v1.RestartPolicyNever,
+		TerminationGracePeriodSeconds: &terminationGracePeriodSeconds,
+		ServiceAccountName:            m.Namespace,
+		ImagePullSecrets:              localObjectReferences(m.ImagePullSecrets),
+		NodeSelector:                  nodeSelector,
+		Tolerations:                   tolerations,
+		Affinity:                      affinity,
+	}, nil
+}
+
+// Pod return the pod that CreatePod would create without creating it
+func Pod(m models.Pods) (pod *v1.Pod, err error) {
+	spec, err := podSpec(m)
+	if err != nil {
+		return
+	}
+	return &v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: m.GenerateName,
 			Namespace:    m.Namespace,
 			Labels:       m.Labels,
 			Annotations:  m.Annotations,
 		},
-		Spec: v1.PodSpec{
-			Containers: []v1.Container{
-				{
-					Name:            m.Container.Name,
-					Image:           m.Container.Image,
-					Command:         m.Container.Command,
-					Env:             envs,
-					ImagePullPolicy: v1.PullIfNotPresent,
-				},
-			},
-			RestartPolicy:                 v1.RestartPolicyNever,
-			TerminationGracePeriodSeconds: &terminationGracePeriodSeconds,
-			ServiceAccountName:            m.Namespace,
-			Affinity: &v1.Affinity{
-				PodAntiAffinity: &v1.PodAntiAffinity{
-					PreferredDuringSchedulingIgnoredDuringExecution: []v1.WeightedPodAffinityTerm{
-						{
-							Weight: int32(5),
-							PodAffinityTerm: v1.PodAffinityTerm{
-								LabelSelector: &metav1.LabelSelector{
-									MatchLabels: map[string]string{
-										"worker": "kubernetes",
-									},
-								},
-								Namespaces: []string{
-									m.Namespace,
-								},
-								TopologyKey: "kubernetes.io/hostname",
-							},
-						},
-					},
-				},
-			},
-		},
+		Spec: spec,
+	}, nil
+}
+
+// CreatePod permit to create pod inside of specified namespace
+func CreatePod(clientset kubernetes.Interface, m models.Pods) (podName string, err error) {
+	pod, err := Pod(m)
+	if err != nil {
+		return "", err
 	}
 	result, err := clientset.
 		CoreV1().
@@ -202,8 +513,74 @@ func CreatePod(clientset *kubernetes.Clientset, m models.Pods) (podName string,
 	return result.Name, nil
 }
 
+// Job return the job that CreateJob would create without creating it
+func Job(m models.Jobs) (job *batchv1.Job, err error) {
+	spec, err := podSpec(m.Pod)
+	if err != nil {
+		return
+	}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: m.Pod.GenerateName,
+			Namespace:    m.Pod.Namespace,
+			Labels:       m.Pod.Labels,
+			Annotations:  m.Pod.Annotations,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &m.BackoffLimit,
+			ActiveDeadlineSeconds:   &m.ActiveDeadlineSeconds,
+			TTLSecondsAfterFinished: &m.TTLSecondsAfterFinished,
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      m.Pod.Labels,
+					Annotations: m.Pod.Annotations,
+				},
+				Spec: spec,
+			},
+		},
+	}, nil
+}
+
+// CreateJob permit to create job inside of specified namespace.
+// Unlike bare pods, the job controller will recreate the pod
+// if it's lost during a node drain for example
+func CreateJob(clientset kubernetes.Interface, m models.Jobs) (jobName string, err error) {
+	job, err := Job(m)
+	if err != nil {
+		return "", err
+	}
+	result, err := clientset.
+		BatchV1().
+		Jobs(m.Pod.Namespace).
+		Create(
+			context.TODO(),
+			job,
+			metav1.CreateOptions{},
+		)
+	if err != nil {
+		return "", err
+	}
+	return result.Name, nil
+}
+
+// DeleteJob permit to delete job and its pods inside of specified namespace
+func DeleteJob(clientset kubernetes.Interface, namespace string, jobName string) (err error) {
+	propagationPolicy := metav1.DeletePropagationBackground
+	err = clientset.
+		BatchV1().
+		Jobs(namespace).
+		Delete(
+			context.TODO(),
+			jobName,
+			metav1.DeleteOptions{
+				PropagationPolicy: &propagationPolicy,
+			},
+		)
+	return
+}
+
 // DeletePod permit to delete pod inside of specified namespace
-func DeletePod(clientset *kubernetes.Clientset, namespace string, podName string) (err error) {
+func DeletePod(clientset kubernetes.Interface, namespace string, podName string) (err error) {
 	err = clientset.
 		CoreV1().
 		Pods(namespace).
@@ -214,3 +591,134 @@ func DeletePod(clientset *kubernetes.Clientset, namespace string, podName string
 		)
 	return
 }
+
+// podWaitingFailures is the list of waiting reasons for which a container
+// will never be able to start by itself
+var podWaitingFailures = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// PodFailureReason return why the pod failed or an empty string
+// if the pod is still healthy or succeeded
+func PodFailureReason(pod *v1.Pod) string {
+	if pod.Status.Reason == "Evicted" {
+		return fmt.Sprintf("Evicted: %s", pod.Status.Message)
+	}
+
+	statuses := append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if status.State.Waiting != nil && podWaitingFailures[status.State.Waiting.Reason] {
+			return fmt.Sprintf("%s: %s", status.State.Waiting.Reason, status.State.Waiting.Message)
+		}
+		if status.State.Terminated != nil {
+			if status.State.Terminated.Reason == "OOMKilled" {
+				return fmt.Sprintf("OOMKilled: container %s exceeded its memory limit", status.Name)
+			}
+			if status.State.Terminated.ExitCode != 0 {
+				return fmt.Sprintf("%s: container %s exited with code %d", status.State.Terminated.Reason, status.Name, status.State.Terminated.ExitCode)
+			}
+		}
+	}
+
+	if pod.Status.Phase == v1.PodFailed {
+		return fmt.Sprintf("Failed: %s", pod.Status.Message)
+	}
+	return ""
+}
+
+// WatchPods permit to watch pods matching labelSelector inside of specified namespace
+// and call onFailure each time one of them is detected as failed.
+// It blocks until stopCh is closed
+func WatchPods(clientset kubernetes.Interface, namespace string, labelSelector string, onFailure func(podName string, reason string), stopCh <-chan struct{}) {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		clientset,
+		0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = labelSelector
+		}),
+	)
+
+	handle := func(obj interface{}) {
+		pod, ok := obj.(*v1.Pod)
+		if !ok {
+			return
+		}
+		if reason := PodFailureReason(pod); reason != "" {
+			onFailure(pod.Name, reason)
+		}
+	}
+
+	informer := factory.Core().V1().Pods().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: handle,
+		UpdateFunc: func(_, newObj interface{}) {
+			handle(newObj)
+		},
+	})
+
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+	<-stopCh
+}
+
+// JobStatus return the status of the job which can be
+// PENDING, RUNNING, SUCCEEDED or FAILED alongside the failure reason if any
+func JobStatus(job *batchv1.Job) (status string, reason string) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != v1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return "SUCCEEDED", ""
+		case batchv1.JobFailed:
+			return "FAILED", fmt.Sprintf("%s: %s", condition.Reason, condition.Message)
+		}
+	}
+	if job.Status.Active > 0 {
+		return "RUNNING", ""
+	}
+	return "PENDING", ""
+}
+
+// WatchJobs permit to watch jobs matching labelSelector inside of specified namespace
+// and call onStatus each time the status of one of them is updated.
+// It blocks until stopCh is closed
+func WatchJobs(clientset kubernetes.Interface, namespace string, labelSelector string, onStatus func(jobName string, status string, reason string), stopCh <-chan struct{}) {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		clientset,
+		0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = labelSelector
+		}),
+	)
+
+	handle := func(obj interface{}) {
+		job, ok := obj.(*batchv1.Job)
+		if !ok {
+			return
+		}
+		status, reason := JobStatus(job)
+		onStatus(job.Name, status, reason)
+	}
+
+	informer := factory.Batch().V1().Jobs().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: handle,
+		UpdateFunc: func(_, newObj interface{}) {
+			handle(newObj)
+		},
+	})
+
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+	<-stopCh
+}
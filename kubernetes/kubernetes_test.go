@@ -2,13 +2,22 @@
 package kubernetes
 
 import (
+	"context"
+	"fmt"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/Lord-Y/cypress-parallel-api/kubernetes/fakeclient"
 	"github.com/Lord-Y/cypress-parallel-api/models"
+	"github.com/gin-gonic/gin"
 	"github.com/icrowley/fake"
 	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestGetNamespace(t *testing.T) {
@@ -107,3 +116,532 @@ func TestClient_fail_client_kubeconfig(t *testing.T) {
 		_ = CreateNamespace(client, name)
 	})
 }
+
+func TestPodFailureReason(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		name     string
+		status   v1.PodStatus
+		expected string
+	}{
+		{
+			name: "running",
+			status: v1.PodStatus{
+				Phase: v1.PodRunning,
+				ContainerStatuses: []v1.ContainerStatus{
+					{Name: "cypress", State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}},
+				},
+			},
+		},
+		{
+			name: "succeeded",
+			status: v1.PodStatus{
+				Phase: v1.PodSucceeded,
+				ContainerStatuses: []v1.ContainerStatus{
+					{Name: "cypress", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Completed"}}},
+				},
+			},
+		},
+		{
+			name: "evicted",
+			status: v1.PodStatus{
+				Phase:   v1.PodFailed,
+				Reason:  "Evicted",
+				Message: "The node was low on resource: memory.",
+			},
+			expected: "Evicted: The node was low on resource: memory.",
+		},
+		{
+			name: "oomkilled",
+			status: v1.PodStatus{
+				Phase: v1.PodFailed,
+				ContainerStatuses: []v1.ContainerStatus{
+					{Name: "cypress", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}}},
+				},
+			},
+			expected: "OOMKilled: container cypress exceeded its memory limit",
+		},
+		{
+			name: "image_pull",
+			status: v1.PodStatus{
+				Phase: v1.PodPending,
+				ContainerStatuses: []v1.ContainerStatus{
+					{Name: "cypress", State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ErrImagePull", Message: "not found"}}},
+				},
+			},
+			expected: "ErrImagePull: not found",
+		},
+		{
+			name: "exit_code",
+			status: v1.PodStatus{
+				Phase: v1.PodFailed,
+				ContainerStatuses: []v1.ContainerStatus{
+					{Name: "cypress", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}}},
+				},
+			},
+			expected: "Error: container cypress exited with code 1",
+		},
+	}
+
+	for _, tc := range tests {
+		pod := &v1.Pod{Status: tc.status}
+		assert.Equal(tc.expected, PodFailureReason(pod), tc.name)
+	}
+}
+
+func TestJobStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		name           string
+		status         batchv1.JobStatus
+		expectedStatus string
+		expectedReason string
+	}{
+		{
+			name:           "pending",
+			expectedStatus: "PENDING",
+		},
+		{
+			name: "running",
+			status: batchv1.JobStatus{
+				Active: 1,
+			},
+			expectedStatus: "RUNNING",
+		},
+		{
+			name: "succeeded",
+			status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{
+					{Type: batchv1.JobComplete, Status: v1.ConditionTrue},
+				},
+			},
+			expectedStatus: "SUCCEEDED",
+		},
+		{
+			name: "failed",
+			status: batchv1.JobStatus{
+				Active: 1,
+				Conditions: []batchv1.JobCondition{
+					{Type: batchv1.JobFailed, Status: v1.ConditionTrue, Reason: "DeadlineExceeded", Message: "Job was active longer than specified deadline"},
+				},
+			},
+			expectedStatus: "FAILED",
+			expectedReason: "DeadlineExceeded: Job was active longer than specified deadline",
+		},
+	}
+
+	for _, tc := range tests {
+		status, reason := JobStatus(&batchv1.Job{Status: tc.status})
+		assert.Equal(tc.expectedStatus, status, tc.name)
+		assert.Equal(tc.expectedReason, reason, tc.name)
+	}
+}
+
+func TestClientFrom(t *testing.T) {
+	assert := assert.New(t)
+
+	client := fakeclient.New()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	Inject(client)(c)
+
+	clientset, err := ClientFrom(c)
+	assert.NoError(err)
+	assert.Equal(client, clientset)
+}
+
+func TestCreatePod_fake_client(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		pod models.Pods
+	)
+
+	client := fakeclient.New()
+	name := fake.CharactersN(10)
+
+	err := GetNamespace(client, name)
+	assert.Error(err)
+	err = CreateNamespace(client, name)
+	assert.NoError(err)
+	err = GetNamespace(client, name)
+	assert.NoError(err)
+
+	err = GetServiceAccountName(client, name, name)
+	assert.Error(err)
+	_, err = CreateServiceAccountName(client, name, name)
+	assert.NoError(err)
+	err = GetServiceAccountName(client, name, name)
+	assert.NoError(err)
+
+	pod.GenerateName = "cypress-parallel-jobs-"
+	pod.Namespace = name
+	pod.Labels = map[string]string{
+		"app": "cypress-parallel-jobs",
+	}
+	pod.Container.Name = "alpine"
+	pod.Container.Image = "alpine:latest"
+	pod.Container.Command = []string{
+		"ls",
+	}
+	pod.Container.EnvironmentVars = []models.EnvironmentVar{
+		{
+			Key:   "key",
+			Value: "value",
+		},
+	}
+	pod.Container.Resources = models.Resources{
+		CPURequest:    "500m",
+		MemoryRequest: "1Gi",
+		MemoryLimit:   "2Gi",
+	}
+
+	podName, err := CreatePod(client, pod)
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(podName, pod.GenerateName))
+
+	result, err := client.CoreV1().Pods(name).Get(context.TODO(), podName, metav1.GetOptions{})
+	assert.NoError(err)
+	assert.Equal(v1.RestartPolicyNever, result.Spec.RestartPolicy)
+	assert.Equal(name, result.Spec.ServiceAccountName)
+	assert.Equal("value", result.Spec.Containers[0].Env[0].Value)
+	assert.Equal("500m", result.Spec.Containers[0].Resources.Requests.Cpu().String())
+	assert.Equal("2Gi", result.Spec.Containers[0].Resources.Limits.Memory().String())
+	assert.True(result.Spec.Containers[0].Resources.Limits.Cpu().IsZero())
+
+	err = DeletePod(client, name, podName)
+	assert.NoError(err)
+	_, err = client.CoreV1().Pods(name).Get(context.TODO(), podName, metav1.GetOptions{})
+	assert.Error(err)
+
+	err = DeleteNamespace(client, name)
+	assert.NoError(err)
+}
+
+func TestCreateJob_fake_client(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		job models.Jobs
+	)
+
+	client := fakeclient.New()
+	name := fake.CharactersN(10)
+
+	job.Pod.GenerateName = "cypress-parallel-jobs-"
+	job.Pod.Namespace = name
+	job.Pod.Labels = map[string]string{
+		"app": "cypress-parallel-jobs",
+	}
+	job.Pod.Container.Name = "alpine"
+	job.Pod.Container.Image = "alpine:latest"
+	job.Pod.Container.Command = []string{
+		"ls",
+	}
+	job.BackoffLimit = 2
+	job.ActiveDeadlineSeconds = 900
+	job.TTLSecondsAfterFinished = 3600
+
+	jobName, err := CreateJob(client, job)
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(jobName, job.Pod.GenerateName))
+
+	result, err := client.BatchV1().Jobs(name).Get(context.TODO(), jobName, metav1.GetOptions{})
+	assert.NoError(err)
+	assert.Equal(int32(2), *result.Spec.BackoffLimit)
+	assert.Equal(int64(900), *result.Spec.ActiveDeadlineSeconds)
+	assert.Equal(int32(3600), *result.Spec.TTLSecondsAfterFinished)
+	assert.Equal(job.Pod.Labels, result.Spec.Template.Labels)
+	assert.Equal(v1.RestartPolicyNever, result.Spec.Template.Spec.RestartPolicy)
+
+	err = DeleteJob(client, name, jobName)
+	assert.NoError(err)
+	_, err = client.BatchV1().Jobs(name).Get(context.TODO(), jobName, metav1.GetOptions{})
+	assert.Error(err)
+}
+
+func TestWatchPods_fake_client(t *testing.T) {
+	assert := assert.New(t)
+
+	client := fakeclient.New()
+	name := fake.CharactersN(10)
+	failures := make(chan string, 1)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	go WatchPods(client, name, "app=cypress-parallel-jobs", func(podName string, reason string) {
+		failures <- fmt.Sprintf("%s %s", podName, reason)
+	}, stopCh)
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cypress-parallel-jobs-oom",
+			Namespace: name,
+			Labels: map[string]string{
+				"app": "cypress-parallel-jobs",
+			},
+		},
+		Status: v1.PodStatus{
+			Phase: v1.PodFailed,
+			ContainerStatuses: []v1.ContainerStatus{
+				{Name: "cypress", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}}},
+			},
+		},
+	}
+	_, err := client.CoreV1().Pods(name).Create(context.TODO(), pod, metav1.CreateOptions{})
+	assert.NoError(err)
+
+	select {
+	case failure := <-failures:
+		assert.Equal("cypress-parallel-jobs-oom OOMKilled: container cypress exceeded its memory limit", failure)
+	case <-time.After(10 * time.Second):
+		t.Fatal("pod failure not detected")
+	}
+}
+
+func TestValidateResources(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		resources models.Resources
+		fail      bool
+	}{
+		{
+			resources: models.Resources{},
+		},
+		{
+			resources: models.Resources{CPURequest: "500m", CPULimit: "1", MemoryRequest: "1Gi", MemoryLimit: "2Gi"},
+		},
+		{
+			resources: models.Resources{MemoryLimit: "2Gi"},
+		},
+		{
+			resources: models.Resources{CPURequest: "fake"},
+			fail:      true,
+		},
+		{
+			resources: models.Resources{MemoryLimit: "2Go"},
+			fail:      true,
+		},
+		{
+			resources: models.Resources{CPURequest: "2", CPULimit: "500m"},
+			fail:      true,
+		},
+	}
+
+	for _, tc := range tests {
+		err := ValidateResources(tc.resources)
+		if tc.fail {
+			assert.Error(err, tc.resources)
+		} else {
+			assert.NoError(err, tc.resources)
+		}
+	}
+}
+
+func TestValidatePlacement(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		placement models.Placement
+		fail      bool
+	}{
+		{
+			placement: models.Placement{},
+		},
+		{
+			placement: models.Placement{
+				NodeSelector: `{"pool": "browsers"}`,
+				Tolerations:  `[{"key": "browsers", "operator": "Exists", "effect": "NoSchedule"}]`,
+				Affinity:     `{"nodeAffinity": {"requiredDuringSchedulingIgnoredDuringExecution": {"nodeSelectorTerms": [{"matchExpressions": [{"key": "pool", "operator": "In", "values": ["browsers"]}]}]}}}`,
+			},
+		},
+		{
+			placement: models.Placement{NodeSelector: `["pool"]`},
+			fail:      true,
+		},
+		{
+			placement: models.Placement{Tolerations: `{"key": "browsers"}`},
+			fail:      true,
+		},
+		{
+			placement: models.Placement{Affinity: `fake`},
+			fail:      true,
+		},
+	}
+
+	for _, tc := range tests {
+		err := ValidatePlacement(tc.placement)
+		if tc.fail {
+			assert.Error(err, tc.placement)
+		} else {
+			assert.NoError(err, tc.placement)
+		}
+	}
+}
+
+func TestCreatePod_fake_client_placement(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		pod models.Pods
+	)
+
+	client := fakeclient.New()
+	name := fake.CharactersN(10)
+
+	pod.GenerateName = "cypress-parallel-jobs-"
+	pod.Namespace = name
+	pod.Container.Name = "alpine"
+	pod.Container.Image = "alpine:latest"
+
+	podName, err := CreatePod(client, pod)
+	assert.NoError(err)
+	result, err := client.CoreV1().Pods(name).Get(context.TODO(), podName, metav1.GetOptions{})
+	assert.NoError(err)
+	assert.Nil(result.Spec.NodeSelector)
+	assert.Empty(result.Spec.Tolerations)
+	assert.NotNil(result.Spec.Affinity.PodAntiAffinity)
+
+	pod.Placement = models.Placement{
+		NodeSelector: `{"pool": "browsers"}`,
+		Tolerations:  `[{"key": "browsers", "operator": "Exists", "effect": "NoSchedule"}]`,
+		Affinity:     `{"nodeAffinity": {"requiredDuringSchedulingIgnoredDuringExecution": {"nodeSelectorTerms": [{"matchExpressions": [{"key": "pool", "operator": "In", "values": ["browsers"]}]}]}}}`,
+	}
+	podName, err = CreatePod(client, pod)
+	assert.NoError(err)
+	result, err = client.CoreV1().Pods(name).Get(context.TODO(), podName, metav1.GetOptions{})
+	assert.NoError(err)
+	assert.Equal(map[string]string{"pool": "browsers"}, result.Spec.NodeSelector)
+	assert.Equal(v1.TaintEffectNoSchedule, result.Spec.Tolerations[0].Effect)
+	assert.Nil(result.Spec.Affinity.PodAntiAffinity)
+	assert.NotNil(result.Spec.Affinity.NodeAffinity)
+
+	pod.Placement = models.Placement{NodeSelector: "fake"}
+	_, err = CreatePod(client, pod)
+	assert.Error(err)
+}
+
+func TestValidateImagePullSecrets(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(ValidateImagePullSecrets(nil))
+	assert.NoError(ValidateImagePullSecrets([]string{"registry", "mirror.example.com"}))
+	assert.Error(ValidateImagePullSecrets([]string{"Registry_Secret"}))
+}
+
+func TestServiceAccountImagePullSecrets_fake_client(t *testing.T) {
+	assert := assert.New(t)
+
+	client := fakeclient.New()
+	name := fake.CharactersN(10)
+
+	_, err := CreateServiceAccountName(client, name, name, "registry", "registry")
+	assert.NoError(err)
+	sa, err := client.CoreV1().ServiceAccounts(name).Get(context.TODO(), name, metav1.GetOptions{})
+	assert.NoError(err)
+	assert.Equal([]v1.LocalObjectReference{{Name: "registry"}}, sa.ImagePullSecrets)
+
+	err = SetServiceAccountImagePullSecrets(client, name, name, "registry", "mirror")
+	assert.NoError(err)
+	sa, err = client.CoreV1().ServiceAccounts(name).Get(context.TODO(), name, metav1.GetOptions{})
+	assert.NoError(err)
+	assert.Equal([]v1.LocalObjectReference{{Name: "registry"}, {Name: "mirror"}}, sa.ImagePullSecrets)
+
+	err = SetServiceAccountImagePullSecrets(client, name, fake.CharactersN(10), "registry")
+	assert.Error(err)
+
+	var pod models.Pods
+	pod.GenerateName = "cypress-parallel-jobs-"
+	pod.Namespace = name
+	pod.Container.Name = "alpine"
+	pod.Container.Image = "registry.example.com/alpine:latest"
+	pod.ImagePullSecrets = []string{"registry", "mirror"}
+	podName, err := CreatePod(client, pod)
+	assert.NoError(err)
+	result, err := client.CoreV1().Pods(name).Get(context.TODO(), podName, metav1.GetOptions{})
+	assert.NoError(err)
+	assert.Equal([]v1.LocalObjectReference{{Name: "registry"}, {Name: "mirror"}}, result.Spec.ImagePullSecrets)
+}
+
+func TestSecret_fake_client(t *testing.T) {
+	assert := assert.New(t)
+
+	client := fakeclient.New()
+	name := fake.CharactersN(10)
+	secretName := ProjectSecretName("1")
+	assert.Equal("cypress-parallel-project-1", secretName)
+
+	err := ApplySecret(client, name, secretName, nil, map[string]string{"username": "user", "password": "pass"})
+	assert.NoError(err)
+	secret, err := client.CoreV1().Secrets(name).Get(context.TODO(), secretName, metav1.GetOptions{})
+	assert.NoError(err)
+	assert.Equal("pass", secret.StringData["password"])
+
+	err = ApplySecret(client, name, secretName, nil, map[string]string{"username": "user", "password": "updated"})
+	assert.NoError(err)
+	secret, err = client.CoreV1().Secrets(name).Get(context.TODO(), secretName, metav1.GetOptions{})
+	assert.NoError(err)
+	assert.Equal("updated", secret.StringData["password"])
+
+	var pod models.Pods
+	pod.GenerateName = "cypress-parallel-jobs-"
+	pod.Namespace = name
+	pod.Container.Name = "alpine"
+	pod.Container.Image = "alpine:latest"
+	pod.Container.Command = []string{"echo", "$(GIT_PASSWORD)"}
+	pod.Container.EnvironmentVars = []models.EnvironmentVar{
+		{
+			Key:        "GIT_PASSWORD",
+			SecretName: secretName,
+			SecretKey:  "password",
+		},
+	}
+	podName, err := CreatePod(client, pod)
+	assert.NoError(err)
+	result, err := client.CoreV1().Pods(name).Get(context.TODO(), podName, metav1.GetOptions{})
+	assert.NoError(err)
+	env := result.Spec.Containers[0].Env[0]
+	assert.Empty(env.Value)
+	assert.Equal(secretName, env.ValueFrom.SecretKeyRef.Name)
+	assert.Equal("password", env.ValueFrom.SecretKeyRef.Key)
+
+	err = DeleteSecret(client, name, secretName)
+	assert.NoError(err)
+	_, err = client.CoreV1().Secrets(name).Get(context.TODO(), secretName, metav1.GetOptions{})
+	assert.Error(err)
+}
+
+func TestCreatePod_fake_client_secret_volumes(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		pod models.Pods
+	)
+
+	client := fakeclient.New()
+	name := fake.CharactersN(10)
+
+	pod.GenerateName = "cypress-parallel-jobs-"
+	pod.Namespace = name
+	pod.Container.Name = "alpine"
+	pod.Container.Image = "alpine:latest"
+	pod.Container.SecretVolumes = []models.SecretVolume{
+		{
+			Name:       "git-ssh",
+			SecretName: ProjectSecretName("1"),
+			MountPath:  "/etc/ssh",
+			Keys:       []string{"ssh-privatekey", "known_hosts"},
+		},
+	}
+
+	podName, err := CreatePod(client, pod)
+	assert.NoError(err)
+	result, err := client.CoreV1().Pods(name).Get(context.TODO(), podName, metav1.GetOptions{})
+	assert.NoError(err)
+	if !assert.Len(result.Spec.Volumes, 1) {
+		return
+	}
+	volume := result.Spec.Volumes[0]
+	assert.Equal(ProjectSecretName("1"), volume.Secret.SecretName)
+	assert.Equal(int32(0400), *volume.Secret.DefaultMode)
+	assert.Equal([]v1.KeyToPath{{Key: "ssh-privatekey", Path: "ssh-privatekey"}, {Key: "known_hosts", Path: "known_hosts"}}, volume.Secret.Items)
+	assert.Equal([]v1.VolumeMount{{Name: "git-ssh", MountPath: "/etc/ssh", ReadOnly: true}}, result.Spec.Containers[0].VolumeMounts)
+}
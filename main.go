@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,11 +12,21 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Lord-Y/cypress-parallel-api/commons"
+	"github.com/Lord-Y/cypress-parallel-api/git"
 	"github.com/Lord-Y/cypress-parallel-api/hooks"
+	"github.com/Lord-Y/cypress-parallel-api/kubernetes"
 	customLogger "github.com/Lord-Y/cypress-parallel-api/logger"
 	"github.com/Lord-Y/cypress-parallel-api/postgres"
+	"github.com/Lord-Y/cypress-parallel-api/projects"
 	"github.com/Lord-Y/cypress-parallel-api/routers"
 	"github.com/rs/zerolog/log"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+var (
+	reencrypt = flag.Bool("reencrypt", false, "encrypt secret columns of all projects with the first encryption key and exit")
+	decrypt   = flag.Bool("decrypt", false, "store secret columns of all projects as plaintext before migrating down the database and exit")
 )
 
 // init func
@@ -32,19 +44,49 @@ func init() {
 
 func main() {
 	var srv *http.Server
-	router := routers.SetupRouter()
+	flag.Parse()
+	if *reencrypt {
+		count, err := projects.Reencrypt()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error occured while re-encrypting projects")
+			return
+		}
+		log.Info().Msgf("%d projects re-encrypted", count)
+		return
+	}
+	if *decrypt {
+		count, err := projects.DecryptAll()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error occured while decrypting projects")
+			return
+		}
+		log.Info().Msgf("%d projects decrypted", count)
+		return
+	}
+
+	clientset, err := kubernetes.Client()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error occured while initializing kubernetes client")
+		return
+	}
+	router := routers.SetupRouterWithKubernetesClient(clientset)
+	// requests context is cancelled on shutdown so long polling requests like runs wait don't hold it
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	baseContext := func(net.Listener) context.Context { return baseCtx }
 
 	appPort := strings.TrimSpace(os.Getenv("CYPRESS_PARALLEL_API_PORT"))
 	if appPort != "" {
 		srv = &http.Server{
-			Addr:    fmt.Sprintf(":%s", appPort),
-			Handler: router,
+			Addr:        fmt.Sprintf(":%s", appPort),
+			Handler:     router,
+			BaseContext: baseContext,
 		}
 		log.Info().Msgf("Starting server on port %s", appPort)
 	} else {
 		srv = &http.Server{
-			Addr:    ":8080",
-			Handler: router,
+			Addr:        ":8080",
+			Handler:     router,
+			BaseContext: baseContext,
 		}
 		log.Info().Msg("Starting server on port 8080")
 	}
@@ -55,7 +97,13 @@ func main() {
 		}
 	}()
 
-	go queued()
+	stopWatching := make(chan struct{})
+	go queued(clientset)
+	go pending(clientset)
+	go scheduling(clientset)
+	go hooks.Watch(clientset, stopWatching)
+	go reaper(clientset)
+	go evictGitMirrors()
 
 	// Wait for interrupt signal to gracefully shutdown the server with
 	// a timeout of 5 seconds.
@@ -66,6 +114,8 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Info().Msg("Shutting down server")
+	close(stopWatching)
+	cancelRequests()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -75,8 +125,36 @@ func main() {
 	log.Info().Msg("Server exited successfully")
 }
 
-func queued() {
+func queued(clientset k8s.Interface) {
+	for range time.Tick(30 * time.Second) {
+		hooks.Queued(clientset)
+	}
+}
+
+func pending(clientset k8s.Interface) {
+	// runs lost by a former api instance are recovered right away
+	hooks.Pending(clientset)
 	for range time.Tick(30 * time.Second) {
-		hooks.Queued()
+		hooks.Pending(clientset)
+	}
+}
+
+func scheduling(clientset k8s.Interface) {
+	for range time.Tick(30 * time.Second) {
+		hooks.Scheduling(clientset)
+	}
+}
+
+func reaper(clientset k8s.Interface) {
+	for range time.Tick(commons.GetReaperInterval()) {
+		hooks.Reaper(clientset)
+	}
+}
+
+func evictGitMirrors() {
+	for range time.Tick(commons.GetGitCacheEvictionInterval()) {
+		if err := git.Evict(); err != nil {
+			log.Error().Err(err).Msg("Error occured while evicting git mirrors")
+		}
 	}
 }
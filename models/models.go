@@ -3,17 +3,38 @@ package models
 
 // Pods struct will be use by kooks package to create pods in kubernetes cluster
 type Pods struct {
-	GenerateName string            // GenerateName is the prefix that will be use to create the pod name
-	Namespace    string            // Namespace in which the pod will be created
-	Annotations  map[string]string // Annotations to set to the pod
-	Labels       map[string]string // Labels to set to the pod
-	Container    container         // Container requirements
+	GenerateName     string            // GenerateName is the prefix that will be use to create the pod name
+	Namespace        string            // Namespace in which the pod will be created
+	Annotations      map[string]string // Annotations to set to the pod
+	Labels           map[string]string // Labels to set to the pod
+	Container        container         // Container requirements
+	Placement        Placement         // Nodes on which the pod can be scheduled
+	ImagePullSecrets []string          // Names of the secrets used to pull the container image
 }
 
-// EnvironmentVar k/v to set inside of the container
+// Placement hold json representations of kubernetes node selector, tolerations and affinity.
+// Empty values are not set except affinity which then prefer to spread pods across nodes
+type Placement struct {
+	NodeSelector string // Node selector like {"pool": "browsers"}
+	Tolerations  string // List of tolerations like [{"key": "browsers", "operator": "Exists", "effect": "NoSchedule"}]
+	Affinity     string // Affinity like {"nodeAffinity": {...}}
+}
+
+// Jobs struct will be use by hooks package to create jobs in kubernetes cluster
+type Jobs struct {
+	Pod                     Pods  // Pod requirements run by the job
+	BackoffLimit            int32 // Number of retries before marking the job as failed
+	ActiveDeadlineSeconds   int64 // Duration in seconds the job may be active before being terminated
+	TTLSecondsAfterFinished int32 // Duration in seconds the finished job is kept before being deleted
+}
+
+// EnvironmentVar k/v to set inside of the container.
+// When SecretName is set, the value is read from the key SecretKey of the secret instead of Value
 type EnvironmentVar struct {
-	Key   string // Variable key
-	Value string // Variable value
+	Key        string // Variable key
+	Value      string // Variable value
+	SecretName string // Name of the secret holding the value
+	SecretKey  string // Key of the secret holding the value
 }
 
 // container hold the configuration that will be use to create pod
@@ -22,4 +43,24 @@ type container struct {
 	Image           string           // Docker image name
 	Command         []string         // Command to run inside of the container
 	EnvironmentVars []EnvironmentVar // Environments variables to set inside of the container
+	Resources       Resources        // CPU and memory requests and limits of the container
+	SecretVolumes   []SecretVolume   // Secrets mounted read only inside of the container
+}
+
+// SecretVolume hold the secret to mount inside of the container.
+// Only keys listed are mounted with read only permissions for the owner
+type SecretVolume struct {
+	Name       string   // Volume name
+	SecretName string   // Name of the secret to mount
+	MountPath  string   // Directory in which keys of the secret are mounted as files
+	Keys       []string // Keys of the secret to mount
+}
+
+// Resources hold CPU and memory requests and limits as kubernetes quantities like 500m or 1Gi.
+// Empty values are not set
+type Resources struct {
+	CPURequest    string // CPU request
+	CPULimit      string // CPU limit
+	MemoryRequest string // Memory request
+	MemoryLimit   string // Memory limit
 }
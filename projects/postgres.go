@@ -3,13 +3,38 @@ package projects
 
 import (
 	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/Lord-Y/cypress-parallel-api/commons"
+	"github.com/Lord-Y/cypress-parallel-api/encryption"
 	_ "github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 	"github.com/syyongx/php2go"
 )
 
+// secretColumns are the encrypted columns which are never returned by the api.
+// Only <column>_set is returned to tell whether they are set
+var secretColumns = map[string]bool{
+	"password":        true,
+	"webhook_secret":  true,
+	"ssh_private_key": true,
+}
+
+// secretColumn return the encrypted value to store in a secret column
+// or nil to keep the current one when the value is not provided
+func secretColumn(value *string) (z interface{}, err error) {
+	if value == nil {
+		return nil, nil
+	}
+	encrypted, err := encryption.Encrypt(*value)
+	if err != nil {
+		return
+	}
+	return php2go.Addslashes(encrypted), nil
+}
+
 // create will insert projects in DB
 func (p *projects) create() (z int64, err error) {
 	db, err := sql.Open(
@@ -22,7 +47,20 @@ func (p *projects) create() (z int64, err error) {
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare("INSERT INTO projects(project_name, team_id, repository, branch, specs, scheduling, scheduling_enabled, max_pods, cypress_docker_version, username, password, browser, config_file, timeout) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING project_id")
+	password, err := encryption.Encrypt(p.Password)
+	if err != nil {
+		return z, err
+	}
+	webhookSecret, err := encryption.Encrypt(p.WebhookSecret)
+	if err != nil {
+		return z, err
+	}
+	sshPrivateKey, err := encryption.Encrypt(p.SSHPrivateKey)
+	if err != nil {
+		return z, err
+	}
+
+	stmt, err := db.Prepare("INSERT INTO projects(project_name, team_id, repository, branch, specs, scheduling, scheduling_enabled, max_pods, cypress_docker_version, username, password, browser, config_file, timeout, webhook_secret, kubernetes_backend, balancing, cpu_request, cpu_limit, memory_request, memory_limit, node_selector, tolerations, affinity, image_repository, image, image_pull_secrets, ssh_private_key, ssh_known_hosts, specs_include, specs_exclude) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31) RETURNING project_id")
 	if err != nil && err != sql.ErrNoRows {
 		return z, err
 	}
@@ -38,10 +76,27 @@ func (p *projects) create() (z int64, err error) {
 		p.MaxPods,
 		php2go.Addslashes(p.CypressDockerVersion),
 		php2go.Addslashes(p.Username),
-		php2go.Addslashes(p.Password),
+		php2go.Addslashes(password),
 		php2go.Addslashes(p.Browser),
 		php2go.Addslashes(p.ConfigFile),
 		p.Timeout,
+		php2go.Addslashes(webhookSecret),
+		php2go.Addslashes(p.KubernetesBackend),
+		php2go.Addslashes(p.Balancing),
+		php2go.Addslashes(p.CPURequest),
+		php2go.Addslashes(p.CPULimit),
+		php2go.Addslashes(p.MemoryRequest),
+		php2go.Addslashes(p.MemoryLimit),
+		php2go.Addslashes(p.NodeSelector),
+		php2go.Addslashes(p.Tolerations),
+		php2go.Addslashes(p.Affinity),
+		php2go.Addslashes(p.ImageRepository),
+		php2go.Addslashes(p.Image),
+		php2go.Addslashes(strings.Join(commons.SplitList(p.ImagePullSecrets), ",")),
+		php2go.Addslashes(sshPrivateKey),
+		php2go.Addslashes(p.SSHKnownHosts),
+		php2go.Addslashes(p.SpecsInclude),
+		php2go.Addslashes(p.SpecsExclude),
 	).Scan(&z)
 	if err != nil && err != sql.ErrNoRows {
 		return z, err
@@ -97,6 +152,10 @@ func (p *getProjects) read() (z map[string]string, err error) {
 			} else {
 				value = php2go.Stripslashes(string(col))
 			}
+			if secretColumns[columns[i]] {
+				m[fmt.Sprintf("%s_set", columns[i])] = strconv.FormatBool(value != "")
+				continue
+			}
 			m[columns[i]] = value
 		}
 	}
@@ -156,6 +215,10 @@ func (p *listProjects) list() (z []map[string]interface{}, err error) {
 			} else {
 				value = php2go.Stripslashes(string(col))
 			}
+			if secretColumns[columns[i]] {
+				sub[fmt.Sprintf("%s_set", columns[i])] = strconv.FormatBool(value != "")
+				continue
+			}
 			sub[columns[i]] = value
 		}
 		m = append(m, sub)
@@ -292,7 +355,20 @@ func (p *updateProjects) update() (err error) {
 	}
 	defer db.Close()
 
-	stmt, err := db.Prepare("UPDATE projects SET project_name = $1, team_id = $2, repository = $3, branch = $4, specs = $5, scheduling = $6, scheduling_enabled = $7, max_pods = $8, cypress_docker_version = $9, username = $10, password = $11, browser = $12, config_file = $13, timeout = $14 WHERE project_id = $15")
+	password, err := secretColumn(p.Password)
+	if err != nil {
+		return err
+	}
+	webhookSecret, err := secretColumn(p.WebhookSecret)
+	if err != nil {
+		return err
+	}
+	sshPrivateKey, err := secretColumn(p.SSHPrivateKey)
+	if err != nil {
+		return err
+	}
+
+	stmt, err := db.Prepare("UPDATE projects SET project_name = $1, team_id = $2, repository = $3, branch = $4, specs = $5, scheduling = $6, scheduling_enabled = $7, max_pods = $8, cypress_docker_version = $9, username = $10, password = COALESCE($11, password), browser = $12, config_file = $13, timeout = $14, webhook_secret = COALESCE($15, webhook_secret), kubernetes_backend = $16, balancing = $17, cpu_request = $18, cpu_limit = $19, memory_request = $20, memory_limit = $21, node_selector = $22, tolerations = $23, affinity = $24, image_repository = $25, image = $26, image_pull_secrets = $27, ssh_private_key = COALESCE($28, ssh_private_key), ssh_known_hosts = $29, specs_include = $30, specs_exclude = $31, scheduling_next_run = NULL WHERE project_id = $32")
 	if err != nil && err != sql.ErrNoRows {
 		return err
 	}
@@ -308,10 +384,27 @@ func (p *updateProjects) update() (err error) {
 		p.MaxPods,
 		php2go.Addslashes(p.CypressDockerVersion),
 		php2go.Addslashes(p.Username),
-		php2go.Addslashes(p.Password),
+		password,
 		php2go.Addslashes(p.Browser),
 		php2go.Addslashes(p.ConfigFile),
 		p.Timeout,
+		webhookSecret,
+		php2go.Addslashes(p.KubernetesBackend),
+		php2go.Addslashes(p.Balancing),
+		php2go.Addslashes(p.CPURequest),
+		php2go.Addslashes(p.CPULimit),
+		php2go.Addslashes(p.MemoryRequest),
+		php2go.Addslashes(p.MemoryLimit),
+		php2go.Addslashes(p.NodeSelector),
+		php2go.Addslashes(p.Tolerations),
+		php2go.Addslashes(p.Affinity),
+		php2go.Addslashes(p.ImageRepository),
+		php2go.Addslashes(p.Image),
+		php2go.Addslashes(strings.Join(commons.SplitList(p.ImagePullSecrets), ",")),
+		sshPrivateKey,
+		php2go.Addslashes(p.SSHKnownHosts),
+		php2go.Addslashes(p.SpecsInclude),
+		php2go.Addslashes(p.SpecsExclude),
 		p.ProjectID,
 	).Scan()
 	if err != nil && err != sql.ErrNoRows {
@@ -399,6 +492,10 @@ func (p *searchProjects) search() (z []map[string]interface{}, err error) {
 			} else {
 				value = php2go.Stripslashes(string(col))
 			}
+			if secretColumns[columns[i]] {
+				sub[fmt.Sprintf("%s_set", columns[i])] = strconv.FormatBool(value != "")
+				continue
+			}
 			sub[columns[i]] = value
 		}
 		m = append(m, sub)
@@ -408,3 +505,169 @@ func (p *searchProjects) search() (z []map[string]interface{}, err error) {
 	}
 	return m, nil
 }
+
+// projectRepository will be used to access the git repository of a project
+type projectRepository struct {
+	repository    string
+	branch        string
+	specs         string
+	username      string
+	password      string
+	sshPrivateKey string
+	sshKnownHosts string
+	specsInclude  string
+	specsExclude  string
+}
+
+// repository will return the git repository settings of the project with decrypted credentials
+// or sql.ErrNoRows when the project does not exist
+func (p *getProjects) repository() (z projectRepository, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	err = db.QueryRow(
+		"SELECT repository, branch, specs, COALESCE(username, ''), COALESCE(password, ''), COALESCE(ssh_private_key, ''), COALESCE(ssh_known_hosts, ''), COALESCE(specs_include, ''), COALESCE(specs_exclude, '') FROM projects WHERE project_id = $1",
+		p.ProjectID,
+	).Scan(
+		&z.repository,
+		&z.branch,
+		&z.specs,
+		&z.username,
+		&z.password,
+		&z.sshPrivateKey,
+		&z.sshKnownHosts,
+		&z.specsInclude,
+		&z.specsExclude,
+	)
+	if err != nil {
+		return
+	}
+	for _, value := range []*string{&z.repository, &z.branch, &z.specs, &z.username, &z.password, &z.sshPrivateKey, &z.sshKnownHosts, &z.specsInclude, &z.specsExclude} {
+		*value = php2go.Stripslashes(*value)
+	}
+	for _, value := range []*string{&z.password, &z.sshPrivateKey} {
+		*value, err = encryption.Decrypt(*value)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// reencryptProject will be used to re-encrypt secret columns of a project
+type reencryptProject struct {
+	projectID     int
+	password      string
+	webhookSecret string
+	sshPrivateKey string
+}
+
+// Reencrypt permit to encrypt secret columns of all projects with the primary encryption key,
+// including plaintext values stored before encryption was enabled, and return the number of projects updated
+func Reencrypt() (z int, err error) {
+	enabled, err := encryption.Enabled()
+	if err != nil {
+		return
+	}
+	if !enabled {
+		return z, fmt.Errorf("No encryption keys set")
+	}
+	return rewriteSecrets(func(value string) (string, bool, error) {
+		stale, err := encryption.Stale(value)
+		if err != nil || !stale {
+			return value, false, err
+		}
+		plaintext, err := encryption.Decrypt(value)
+		if err != nil {
+			return value, false, err
+		}
+		value, err = encryption.Encrypt(plaintext)
+		return value, err == nil, err
+	})
+}
+
+// DecryptAll permit to store secret columns of all projects as plaintext again
+// so the database can be migrated down before encryption, and return the number of projects updated
+func DecryptAll() (z int, err error) {
+	return rewriteSecrets(func(value string) (string, bool, error) {
+		if !encryption.IsEncrypted(value) {
+			return value, false, nil
+		}
+		value, err := encryption.Decrypt(value)
+		return value, err == nil, err
+	})
+}
+
+// rewriteSecrets will update secret columns of all projects with the values returned by the rewrite function provided
+// and return the number of projects updated
+func rewriteSecrets(rewrite func(value string) (z string, updated bool, err error)) (z int, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to connect to DB")
+		return z, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT project_id, COALESCE(password, ''), COALESCE(webhook_secret, ''), COALESCE(ssh_private_key, '') FROM projects")
+	if err != nil {
+		return
+	}
+	var projects []reencryptProject
+	for rows.Next() {
+		var p reencryptProject
+		err = rows.Scan(&p.projectID, &p.password, &p.webhookSecret, &p.sshPrivateKey)
+		if err != nil {
+			rows.Close()
+			return
+		}
+		p.password = php2go.Stripslashes(p.password)
+		p.webhookSecret = php2go.Stripslashes(p.webhookSecret)
+		p.sshPrivateKey = php2go.Stripslashes(p.sshPrivateKey)
+		projects = append(projects, p)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	stmt, err := db.Prepare("UPDATE projects SET password = $1, webhook_secret = $2, ssh_private_key = $3 WHERE project_id = $4")
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+
+	for _, p := range projects {
+		updated := false
+		for _, value := range []*string{&p.password, &p.webhookSecret, &p.sshPrivateKey} {
+			var changed bool
+			*value, changed, err = rewrite(*value)
+			if err != nil {
+				return
+			}
+			updated = updated || changed
+		}
+		if !updated {
+			continue
+		}
+		_, err = stmt.Exec(
+			php2go.Addslashes(p.password),
+			php2go.Addslashes(p.webhookSecret),
+			php2go.Addslashes(p.sshPrivateKey),
+			p.projectID,
+		)
+		if err != nil {
+			return
+		}
+		z++
+	}
+	return z, nil
+}
@@ -2,13 +2,22 @@
 package projects
 
 import (
+	"database/sql"
+	"fmt"
 	"net/http"
+	"os"
 	"strconv"
 
 	"github.com/Lord-Y/cypress-parallel-api/commons"
+	"github.com/Lord-Y/cypress-parallel-api/discovery"
+	"github.com/Lord-Y/cypress-parallel-api/git"
+	"github.com/Lord-Y/cypress-parallel-api/kubernetes"
+	"github.com/Lord-Y/cypress-parallel-api/models"
 	"github.com/Lord-Y/cypress-parallel-api/tools"
 	"github.com/gin-gonic/gin"
+	"github.com/robfig/cron/v3"
 	"github.com/rs/zerolog/log"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 // projects struct handle requirements to create projects
@@ -27,6 +36,23 @@ type projects struct {
 	Password             string `form:"password" json:"password"`
 	Browser              string `form:"browser,default=chrome" json:"browser" binding:"max=100,oneof=chrome firefox"`
 	ConfigFile           string `form:"config_file,default=cypress.json" json:"config_file" binding:"max=100"`
+	WebhookSecret        string `form:"webhookSecret" json:"webhookSecret" binding:"max=100"`
+	KubernetesBackend    string `form:"kubernetes_backend,default=pod" json:"kubernetes_backend" binding:"max=3,oneof=pod job"`
+	Balancing            string `form:"balancing,default=chunk" json:"balancing" binding:"max=8,oneof=chunk duration"`
+	CPURequest           string `form:"cpu_request" json:"cpu_request" binding:"max=20"`
+	CPULimit             string `form:"cpu_limit" json:"cpu_limit" binding:"max=20"`
+	MemoryRequest        string `form:"memory_request" json:"memory_request" binding:"max=20"`
+	MemoryLimit          string `form:"memory_limit" json:"memory_limit" binding:"max=20"`
+	NodeSelector         string `form:"node_selector" json:"node_selector" binding:"max=5000"`
+	Tolerations          string `form:"tolerations" json:"tolerations" binding:"max=5000"`
+	Affinity             string `form:"affinity" json:"affinity" binding:"max=10000"`
+	ImageRepository      string `form:"image_repository" json:"image_repository" binding:"max=255"`
+	Image                string `form:"image" json:"image" binding:"max=255"`
+	ImagePullSecrets     string `form:"image_pull_secrets" json:"image_pull_secrets" binding:"max=1000"`
+	SSHPrivateKey        string `form:"ssh_private_key" json:"ssh_private_key" binding:"max=10000"`
+	SSHKnownHosts        string `form:"ssh_known_hosts" json:"ssh_known_hosts" binding:"max=10000"`
+	SpecsInclude         string `form:"specs_include" json:"specs_include" binding:"max=1000"`
+	SpecsExclude         string `form:"specs_exclude" json:"specs_exclude" binding:"max=1000"`
 }
 
 // getProjects struct handle requirements to get projects
@@ -42,23 +68,41 @@ type listProjects struct {
 	EndLimit   int
 }
 
-// updateProjects struct handle requirements to update projects
+// updateProjects struct handle requirements to update projects.
+// Password, webhook secret and SSH private key are kept as is when they are not provided
 type updateProjects struct {
-	ProjectID            int    `form:"projectId" json:"projectId" binding:"required"`
-	TeamID               int    `form:"teamId" json:"teamId" binding:"required"`
-	Name                 string `form:"name" json:"name" binding:"required,max=100"`
-	Repository           string `form:"repository" json:"repository" binding:"required"`
-	Branch               string `form:"branch" json:"branch" binding:"required"`
-	Specs                string `form:"specs" json:"specs" binding:"required"`
-	Scheduling           string `form:"scheduling" json:"scheduling" binding:"max=15"`
-	SchedulingEnabled    bool   `form:"schedulingEnabled" json:"schedulingEnabled"`
-	MaxPods              int    `form:"maxPods,default=10" json:"maxPods"`
-	CypressDockerVersion string `form:"cypress_docker_version,default=7.2.0-0.0.5" json:"cypress_docker_version"`
-	Timeout              int    `form:"timeout,default=10" json:"timeout"`
-	Username             string `form:"username" json:"username" binding:"max=100"`
-	Password             string `form:"password" json:"password" binding:"max=100"`
-	Browser              string `form:"browser,default=chrome" json:"browser" binding:"max=100,oneof=chrome firefox"`
-	ConfigFile           string `form:"config_file,default=cypress.json" json:"config_file" binding:"max=100"`
+	ProjectID            int     `form:"projectId" json:"projectId" binding:"required"`
+	TeamID               int     `form:"teamId" json:"teamId" binding:"required"`
+	Name                 string  `form:"name" json:"name" binding:"required,max=100"`
+	Repository           string  `form:"repository" json:"repository" binding:"required"`
+	Branch               string  `form:"branch" json:"branch" binding:"required"`
+	Specs                string  `form:"specs" json:"specs" binding:"required"`
+	Scheduling           string  `form:"scheduling" json:"scheduling" binding:"max=15"`
+	SchedulingEnabled    bool    `form:"schedulingEnabled" json:"schedulingEnabled"`
+	MaxPods              int     `form:"maxPods,default=10" json:"maxPods"`
+	CypressDockerVersion string  `form:"cypress_docker_version,default=7.2.0-0.0.5" json:"cypress_docker_version"`
+	Timeout              int     `form:"timeout,default=10" json:"timeout"`
+	Username             string  `form:"username" json:"username" binding:"max=100"`
+	Password             *string `form:"password" json:"password" binding:"omitempty,max=100"`
+	Browser              string  `form:"browser,default=chrome" json:"browser" binding:"max=100,oneof=chrome firefox"`
+	ConfigFile           string  `form:"config_file,default=cypress.json" json:"config_file" binding:"max=100"`
+	WebhookSecret        *string `form:"webhookSecret" json:"webhookSecret" binding:"omitempty,max=100"`
+	KubernetesBackend    string  `form:"kubernetes_backend,default=pod" json:"kubernetes_backend" binding:"max=3,oneof=pod job"`
+	Balancing            string  `form:"balancing,default=chunk" json:"balancing" binding:"max=8,oneof=chunk duration"`
+	CPURequest           string  `form:"cpu_request" json:"cpu_request" binding:"max=20"`
+	CPULimit             string  `form:"cpu_limit" json:"cpu_limit" binding:"max=20"`
+	MemoryRequest        string  `form:"memory_request" json:"memory_request" binding:"max=20"`
+	MemoryLimit          string  `form:"memory_limit" json:"memory_limit" binding:"max=20"`
+	NodeSelector         string  `form:"node_selector" json:"node_selector" binding:"max=5000"`
+	Tolerations          string  `form:"tolerations" json:"tolerations" binding:"max=5000"`
+	Affinity             string  `form:"affinity" json:"affinity" binding:"max=10000"`
+	ImageRepository      string  `form:"image_repository" json:"image_repository" binding:"max=255"`
+	Image                string  `form:"image" json:"image" binding:"max=255"`
+	ImagePullSecrets     string  `form:"image_pull_secrets" json:"image_pull_secrets" binding:"max=1000"`
+	SSHPrivateKey        *string `form:"ssh_private_key" json:"ssh_private_key" binding:"omitempty,max=10000"`
+	SSHKnownHosts        string  `form:"ssh_known_hosts" json:"ssh_known_hosts" binding:"max=10000"`
+	SpecsInclude         string  `form:"specs_include" json:"specs_include" binding:"max=1000"`
+	SpecsExclude         string  `form:"specs_exclude" json:"specs_exclude" binding:"max=1000"`
 }
 
 // deleteProject struct handle requirements to delete project
@@ -97,6 +141,34 @@ func Create(c *gin.Context) {
 	if p.MaxPods == 0 {
 		p.MaxPods = 10
 	}
+	if err := validateScheduling(p.Scheduling, p.SchedulingEnabled); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := kubernetes.ValidateResources(models.Resources{CPURequest: p.CPURequest, CPULimit: p.CPULimit, MemoryRequest: p.MemoryRequest, MemoryLimit: p.MemoryLimit}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := kubernetes.ValidatePlacement(models.Placement{NodeSelector: p.NodeSelector, Tolerations: p.Tolerations, Affinity: p.Affinity}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := kubernetes.ValidateImagePullSecrets(commons.SplitList(p.ImagePullSecrets)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := git.ValidateSSHPrivateKey(p.SSHPrivateKey); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := git.ValidateSSHKnownHosts(p.SSHKnownHosts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := discovery.ValidatePatterns(discovery.SplitPatterns(p.SpecsInclude + "\n" + p.SpecsExclude)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	result, err := p.create()
 	if err != nil {
@@ -203,6 +275,36 @@ func Update(c *gin.Context) {
 	if p.MaxPods == 0 {
 		p.MaxPods = 10
 	}
+	if err := validateScheduling(p.Scheduling, p.SchedulingEnabled); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := kubernetes.ValidateResources(models.Resources{CPURequest: p.CPURequest, CPULimit: p.CPULimit, MemoryRequest: p.MemoryRequest, MemoryLimit: p.MemoryLimit}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := kubernetes.ValidatePlacement(models.Placement{NodeSelector: p.NodeSelector, Tolerations: p.Tolerations, Affinity: p.Affinity}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := kubernetes.ValidateImagePullSecrets(commons.SplitList(p.ImagePullSecrets)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if p.SSHPrivateKey != nil {
+		if err := git.ValidateSSHPrivateKey(*p.SSHPrivateKey); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := git.ValidateSSHKnownHosts(p.SSHKnownHosts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := discovery.ValidatePatterns(discovery.SplitPatterns(p.SpecsInclude + "\n" + p.SpecsExclude)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	err := p.update()
 	if err != nil {
@@ -238,6 +340,17 @@ func Delete(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
 		return
 	}
+
+	// the project is already deleted so failing to delete its secret is only logged
+	clientset, err := kubernetes.ClientFrom(c)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while initializing kubernetes client")
+	} else {
+		err = kubernetes.DeleteSecret(clientset, commons.GetKubernetesJobsNamespace(), kubernetes.ProjectSecretName(id))
+		if err != nil && !k8serrors.IsNotFound(err) {
+			log.Error().Err(err).Msgf("Error occured while trying to delete secret of project %s", id)
+		}
+	}
 	c.JSON(http.StatusOK, "OK")
 }
 
@@ -265,3 +378,126 @@ func Search(c *gin.Context) {
 		c.JSON(http.StatusOK, result)
 	}
 }
+
+// repositoryOf return the git repository of the project in uri.
+// The error response is already sent when ok is false
+func repositoryOf(c *gin.Context) (z projectRepository, gitc git.Repository, ok bool) {
+	var (
+		p getProjects
+	)
+	id := c.Params.ByName("projectId")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "projectId is missing in uri"})
+		return
+	}
+	vID, err := strconv.Atoi(id)
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while converting string to int")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	p.ProjectID = vID
+
+	z, err = p.repository()
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.AbortWithStatus(404)
+			return
+		}
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	gitc.Repository = z.repository
+	gitc.Username = z.username
+	gitc.Password = z.password
+	gitc.SSHPrivateKey = z.sshPrivateKey
+	gitc.SSHKnownHosts = z.sshKnownHosts
+	if gitc.SSHKnownHosts == "" {
+		gitc.SSHKnownHosts = commons.GetGitSSHKnownHosts()
+	}
+	return z, gitc, true
+}
+
+// Specs handle requirements to return the specs discovered in the repository of the project.
+// The project branch is used unless branch or commit, a commit SHA or a tag, is provided in query
+func Specs(c *gin.Context) {
+	pr, gitc, ok := repositoryOf(c)
+	if !ok {
+		return
+	}
+	gitc.Branch = pr.branch
+	if c.Query("branch") != "" {
+		gitc.Branch = c.Query("branch")
+	}
+	gitc.Revision = c.Query("commit")
+	targetSpecs := pr.specs
+	if c.Query("specs") != "" {
+		targetSpecs = c.Query("specs")
+	}
+
+	gitdir, commit, statusCode, err := gitc.Clone()
+	defer os.RemoveAll(gitdir)
+	if err != nil {
+		if statusCode == http.StatusBadRequest {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Error occured while cloning git repository, error: %s", err.Error())})
+			return
+		}
+		log.Error().Err(err).Msg("Error occured while cloning git repository")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	specs, err := discovery.Find(gitdir, targetSpecs, discovery.SplitPatterns(pr.specsInclude), discovery.SplitPatterns(pr.specsExclude))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Error occured while retrieving specs, error: %s", err.Error())})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"branch": gitc.Branch,
+		"commit": commit,
+		"specs":  specs,
+		"tree":   discovery.Tree(specs),
+	})
+}
+
+// Branches handle requirements to return the branches of the repository of the project.
+// Only remote references are listed so it also permit to check the project can access its repository
+func Branches(c *gin.Context) {
+	_, gitc, ok := repositoryOf(c)
+	if !ok {
+		return
+	}
+
+	branches, head, statusCode, err := gitc.Branches()
+	if err != nil {
+		if statusCode == http.StatusBadRequest {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Error occured while listing git repository branches, error: %s", err.Error())})
+			return
+		}
+		log.Error().Err(err).Msg("Error occured while listing git repository branches")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"default":  head,
+		"branches": branches,
+	})
+}
+
+// validateScheduling ensure that the provided scheduling is a valid cron expression
+func validateScheduling(scheduling string, enabled bool) (err error) {
+	if scheduling == "" {
+		if enabled {
+			return fmt.Errorf("scheduling is required when schedulingEnabled is true")
+		}
+		return nil
+	}
+	_, err = cron.ParseStandard(scheduling)
+	if err != nil {
+		return fmt.Errorf("Invalid scheduling %s, error: %s", scheduling, err.Error())
+	}
+	return nil
+}
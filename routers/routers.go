@@ -6,11 +6,14 @@ import (
 
 	"github.com/Lord-Y/cypress-parallel-api/annotations"
 	"github.com/Lord-Y/cypress-parallel-api/environments"
+	"github.com/Lord-Y/cypress-parallel-api/events"
 	"github.com/Lord-Y/cypress-parallel-api/executions"
 	"github.com/Lord-Y/cypress-parallel-api/health"
 	"github.com/Lord-Y/cypress-parallel-api/hooks"
+	"github.com/Lord-Y/cypress-parallel-api/kubernetes"
 	customLogger "github.com/Lord-Y/cypress-parallel-api/logger"
 	"github.com/Lord-Y/cypress-parallel-api/projects"
+	"github.com/Lord-Y/cypress-parallel-api/runs"
 	"github.com/Lord-Y/cypress-parallel-api/teams"
 	"github.com/Lord-Y/cypress-parallel-api/tools"
 	"github.com/gin-contrib/logger"
@@ -18,6 +21,7 @@ import (
 	ginprometheus "github.com/mcuadros/go-gin-prometheus"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	k8s "k8s.io/client-go/kubernetes"
 )
 
 func init() {
@@ -25,7 +29,14 @@ func init() {
 }
 
 // SetupRouter func handle all routes of the api
+// connecting to the kubernetes cluster when required
 func SetupRouter() *gin.Engine {
+	return SetupRouterWithKubernetesClient(nil)
+}
+
+// SetupRouterWithKubernetesClient func handle all routes of the api
+// with the kubernetes client provided instead of connecting to a cluster when it is not nil
+func SetupRouterWithKubernetesClient(clientset k8s.Interface) *gin.Engine {
 	gin.DisableConsoleColor()
 	gin.SetMode(gin.ReleaseMode)
 	requestID := tools.RandStringInt(32)
@@ -46,6 +57,9 @@ func SetupRouter() *gin.Engine {
 		}
 	}
 	router.Use(headerHandler)
+	if clientset != nil {
+		router.Use(kubernetes.Inject(clientset))
+	}
 	// disable during unit testing
 	if os.Getenv("CYPRESS_PARALLEL_API_PROMETHEUS") != "" {
 		p := ginprometheus.NewPrometheus("http")
@@ -72,6 +86,8 @@ func SetupRouter() *gin.Engine {
 		v1.PUT("/projects", projects.Update)
 		v1.DELETE("/projects/:projectId", projects.Delete)
 		v1.GET("/projects/search", projects.Search)
+		v1.GET("/projects/:projectId/specs", projects.Specs)
+		v1.GET("/projects/:projectId/branches", projects.Branches)
 
 		v1.POST("/environments", environments.Create)
 		v1.PUT("/environments", environments.Update)
@@ -90,12 +106,23 @@ func SetupRouter() *gin.Engine {
 		v1.GET("/annotations/search", annotations.Search)
 
 		v1.POST("/hooks/launch/plain", hooks.Plain)
+		v1.POST("/hooks/launch/github", hooks.GitHub)
+		v1.POST("/hooks/launch/gitlab", hooks.GitLab)
+		v1.POST("/hooks/launch/gitea", hooks.Gitea)
 
 		v1.GET("/executions/list", executions.List)
 		v1.GET("/executions/list/by/uniqid/:uniqId", executions.UniqID)
 		v1.POST("/executions/update", executions.UpdateResultExecution)
+		v1.POST("/executions/cancel/:uniqId", executions.Cancel)
+		v1.POST("/executions/retry/:uniqId", executions.Retry)
 		v1.GET("/executions/:executionId", executions.Read)
 		v1.GET("/executions/search", executions.Search)
+
+		v1.GET("/runs/list", runs.List)
+		v1.GET("/runs/:uniqId", runs.Read)
+		v1.GET("/runs/:uniqId/wait", runs.Wait)
+		v1.GET("/runs/:uniqId/events", runs.Events)
+		v1.GET("/events", events.Stream)
 	}
 	return router
 }
@@ -0,0 +1,125 @@
+package routers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lord-Y/cypress-parallel-api/events"
+	"github.com/Lord-Y/cypress-parallel-api/kubernetes/fakeclient"
+	"github.com/stretchr/testify/assert"
+)
+
+// readEvents return events streamed by the url provided until the context is done
+func readEvents(t *testing.T, ctx context.Context, url string) <-chan events.Event {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	received := make(chan events.Event, 100)
+	go func() {
+		defer resp.Body.Close()
+		defer close(received)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if !strings.HasPrefix(scanner.Text(), "data:") {
+				continue
+			}
+			var e events.Event
+			if json.Unmarshal([]byte(strings.TrimPrefix(scanner.Text(), "data:")), &e) == nil {
+				received <- e
+			}
+		}
+	}()
+	return received
+}
+
+// waitEvent return events received until the one matching the filter provided
+func waitEvent(received <-chan events.Event, filter func(events.Event) bool) (z []events.Event, found bool) {
+	for e := range received {
+		z = append(z, e)
+		if filter(e) {
+			return z, true
+		}
+	}
+	return
+}
+
+func TestEventsStream(t *testing.T) {
+	assert := assert.New(t)
+
+	client := fakeclient.New()
+	router := SetupRouterWithKubernetesClient(client)
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	name := createFakeClientProject(t, 1, "pod")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	all := readEvents(t, ctx, fmt.Sprintf("%s/api/v1/cypress-parallel-api/events", srv.URL))
+
+	uniqID, run := launchPlain(t, router, fmt.Sprintf("project_name=%s", name))
+	if !assert.Equal("LAUNCHED", run["run_status"]) {
+		return
+	}
+	received, found := waitEvent(all, func(e events.Event) bool {
+		return e.UniqID == uniqID && e.Type == "run" && e.Status == "LAUNCHED"
+	})
+	if !assert.True(found) {
+		return
+	}
+	types := make(map[string]bool)
+	projectID := 0
+	for _, e := range received {
+		if e.UniqID == uniqID {
+			types[fmt.Sprintf("%s %s", e.Type, e.Status)] = true
+			projectID = e.ProjectID
+		}
+	}
+	assert.True(types["run PENDING"])
+	assert.True(types["run LAUNCHING"])
+	assert.True(types["execution NOT_STARTED"])
+	assert.True(types["pod RUNNING"])
+	assert.NotZero(projectID)
+
+	runEvents := readEvents(t, ctx, fmt.Sprintf("%s/api/v1/cypress-parallel-api/runs/%s/events", srv.URL, uniqID))
+	pods := podsOfRun(t, client, uniqID)
+	if !assert.Len(pods, 1) {
+		return
+	}
+	reportResults(t, router, pods[0].Spec.Containers[0].Command)
+	received, found = waitEvent(runEvents, func(e events.Event) bool {
+		return e.Type == "result"
+	})
+	if assert.True(found) {
+		result := received[len(received)-1]
+		assert.Equal(uniqID, result.UniqID)
+		assert.Equal(projectID, result.ProjectID)
+		assert.Equal("DONE", result.Status)
+		assert.NotEmpty(result.Spec)
+	}
+}
+
+func TestEventsStream_fail(t *testing.T) {
+	assert := assert.New(t)
+
+	router := SetupRouter()
+	w, _ := performRequest(router, nil, "GET", "/api/v1/cypress-parallel-api/runs/fake/events", "")
+	assert.Equal(404, w.Code)
+
+	w, _ = performRequest(router, nil, "GET", "/api/v1/cypress-parallel-api/events?projectId=fake", "")
+	assert.Equal(400, w.Code)
+}
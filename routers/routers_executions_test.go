@@ -1,10 +1,12 @@
 package routers
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 
 	"github.com/Lord-Y/cypress-parallel-api/executions"
+	"github.com/Lord-Y/cypress-parallel-api/hooks"
 	"github.com/Lord-Y/cypress-parallel-api/projects"
 	"github.com/Lord-Y/cypress-parallel-api/tools"
 	"github.com/rs/zerolog/log"
@@ -27,10 +29,10 @@ func TestExecutionsList(t *testing.T) {
 	router := SetupRouter()
 	payload := fmt.Sprintf("project_name=%s", result["project_name"])
 	payload += fmt.Sprintf("&specs=%s", tools.RandomValueFromSlice(specs))
-	w, _ := performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/hooks/launch/plain", payload)
-	assert.Equal(201, w.Code)
+	_, run := launchPlain(t, router, payload)
+	assert.Equal("LAUNCHED", run["run_status"])
 
-	w, _ = performRequest(router, headers, "GET", "/api/v1/cypress-parallel-api/executions/list", "")
+	w, _ := performRequest(router, headers, "GET", "/api/v1/cypress-parallel-api/executions/list", "")
 	assert.Equal(200, w.Code)
 }
 
@@ -154,3 +156,113 @@ func TestExecutionsUniqID(t *testing.T) {
 	w, _ = performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/executions/list/by/uniqid/%s", "404"), "")
 	assert.Equal(404, w.Code)
 }
+
+func TestExecutionsCancel(t *testing.T) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	TestHooksPlainCreate(t)
+
+	result, err := executions.GetExecutionIDForUnitTesting()
+	if err != nil {
+		assert.Fail("Fail to retrieve executions")
+		return
+	}
+
+	router := SetupRouter()
+	w, _ := performRequest(router, headers, "POST", fmt.Sprintf("/api/v1/cypress-parallel-api/executions/cancel/%s", "404"), "")
+	assert.Equal(404, w.Code)
+
+	if len(result) == 0 {
+		return
+	}
+	w, _ = performRequest(router, headers, "POST", fmt.Sprintf("/api/v1/cypress-parallel-api/executions/cancel/%s", result["uniq_id"]), "")
+	assert.Equal(200, w.Code)
+
+	w, _ = performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/executions/list/by/uniqid/%s", result["uniq_id"]), "")
+	assert.NotContains(w.Body.String(), `"execution_status":"RUNNING"`)
+	assert.NotContains(w.Body.String(), `"execution_status":"QUEUED"`)
+}
+
+func TestExecutionsCancel_pending(t *testing.T) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	TestProjectsCreate(t)
+	result, err := projects.GetProjectIDForUnitTesting()
+	if err != nil {
+		log.Err(err).Msgf("Fail to retrieve project and team id")
+		t.Fail()
+		return
+	}
+	uniqID, err := hooks.CreatePendingRunForUnitTesting(result["project_name"])
+	if !assert.NoError(err) {
+		return
+	}
+
+	// the run has no executions nor pods yet but must be cancelled
+	router := SetupRouter()
+	w, _ := performRequest(router, headers, "POST", fmt.Sprintf("/api/v1/cypress-parallel-api/executions/cancel/%s", uniqID), "")
+	assert.Equal(200, w.Code)
+
+	var run map[string]string
+	w, _ = performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/runs/%s", uniqID), "")
+	if assert.Equal(200, w.Code) && assert.NoError(json.Unmarshal(w.Body.Bytes(), &run)) {
+		assert.Equal("CANCELLED", run["run_status"])
+		assert.Equal("CANCELLED", run["status"])
+	}
+
+	// cancelled runs have nothing left to cancel
+	w, _ = performRequest(router, headers, "POST", fmt.Sprintf("/api/v1/cypress-parallel-api/executions/cancel/%s", uniqID), "")
+	assert.Equal(404, w.Code)
+}
+
+func TestExecutionsRetry(t *testing.T) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	TestHooksPlainCreate(t)
+
+	result, err := executions.GetLatestExecutionForUnitTesting()
+	if err != nil {
+		assert.Fail("Fail to retrieve executions")
+		return
+	}
+
+	router := SetupRouter()
+	w, _ := performRequest(router, headers, "POST", fmt.Sprintf("/api/v1/cypress-parallel-api/executions/retry/%s", "404"), "")
+	assert.Equal(404, w.Code)
+
+	if len(result) == 0 {
+		return
+	}
+	payload := `result={}`
+	payload += "&executionStatus=FAILED"
+	payload += fmt.Sprintf("&branch=%s", result["branch"])
+	payload += fmt.Sprintf("&spec=%s", result["spec"])
+	payload += fmt.Sprintf("&uniqId=%s", result["uniq_id"])
+
+	w, _ = performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/executions/update", payload)
+	assert.Equal(200, w.Code)
+
+	w, _ = performRequest(router, headers, "POST", fmt.Sprintf("/api/v1/cypress-parallel-api/executions/retry/%s", result["uniq_id"]), "")
+	assert.Equal(201, w.Code)
+	assert.Contains(w.Body.String(), fmt.Sprintf(`"parentUniqId":"%s"`, result["uniq_id"]))
+
+	// retried specs must run at the commit of the former run
+	var retried map[string]string
+	if !assert.NoError(json.Unmarshal(w.Body.Bytes(), &retried)) {
+		return
+	}
+	var runs [2]map[string]string
+	for k, uniqID := range []string{result["uniq_id"], retried["uniqId"]} {
+		w, _ = performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/runs/%s", uniqID), "")
+		if !assert.Equal(200, w.Code) || !assert.NoError(json.Unmarshal(w.Body.Bytes(), &runs[k])) {
+			return
+		}
+	}
+	assert.Equal(runs[0]["commit_sha"], runs[1]["commit_sha"])
+}
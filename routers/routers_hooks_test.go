@@ -1,11 +1,17 @@
 package routers
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Lord-Y/cypress-parallel-api/projects"
+	"github.com/Lord-Y/cypress-parallel-api/teams"
 	"github.com/Lord-Y/cypress-parallel-api/tools"
+	"github.com/icrowley/fake"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 )
@@ -25,23 +31,23 @@ func TestHooksPlainCreate(t *testing.T) {
 
 	router := SetupRouter()
 	tests := []struct {
-		branch     string
-		browser    string
-		statusCode int
+		branch    string
+		browser   string
+		runStatus string
 	}{
 		{
-			branch:     "",
-			browser:    "chrome",
-			statusCode: 201,
+			branch:    "",
+			browser:   "chrome",
+			runStatus: "LAUNCHED",
 		},
 		{
-			branch:     "master",
-			browser:    "chrome",
-			statusCode: 201,
+			branch:    "master",
+			browser:   "chrome",
+			runStatus: "LAUNCHED",
 		},
 		{
-			branch:     "test",
-			statusCode: 400,
+			branch:    "test",
+			runStatus: "FAILED",
 		},
 	}
 
@@ -51,13 +57,278 @@ func TestHooksPlainCreate(t *testing.T) {
 			payload += fmt.Sprintf("&branch=%s", tc.branch)
 			payload += fmt.Sprintf("&specs=%s", tools.RandomValueFromSlice(specs))
 		}
-		w, _ := performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/hooks/launch/plain", payload)
-		assert.Equal(tc.statusCode, w.Code)
+		_, run := launchPlain(t, router, payload)
+		assert.Equal(tc.runStatus, run["run_status"])
 	}
 
 	payload := fmt.Sprintf("project_name=%s", result["project_name"])
 	payload += "&branch=master"
 	payload += fmt.Sprintf("&specs=bad_%s", tools.RandomValueFromSlice(specs))
+	_, run := launchPlain(t, router, payload)
+	assert.Equal("FAILED", run["run_status"])
+	assert.Contains(run["reason"], "Error occured while retrieving specs")
+
+	payload = "project_name=fake"
 	w, _ := performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/hooks/launch/plain", payload)
 	assert.Equal(400, w.Code)
 }
+
+// launchPlain launch unit testing with the payload provided, wait for the launch workers
+// to be done with the run and return its uniq id and the run itself
+func launchPlain(t *testing.T, router http.Handler, payload string) (uniqID string, run map[string]string) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	w, _ := performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/hooks/launch/plain", payload)
+	if !assert.Equal(202, w.Code) {
+		return
+	}
+	var accepted map[string]string
+	if !assert.NoError(json.Unmarshal(w.Body.Bytes(), &accepted)) {
+		return
+	}
+	uniqID = accepted["uniqId"]
+	assert.Equal(accepted["statusUrl"], w.Header().Get("Location"))
+	assert.True(strings.HasSuffix(accepted["statusUrl"], fmt.Sprintf("/api/v1/cypress-parallel-api/runs/%s", uniqID)))
+
+	for start := time.Now(); time.Since(start) < 2*time.Minute; time.Sleep(100 * time.Millisecond) {
+		w, _ = performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/runs/%s", uniqID), "")
+		if !assert.Equal(200, w.Code) || !assert.NoError(json.Unmarshal(w.Body.Bytes(), &run)) {
+			return
+		}
+		if run["run_status"] != "PENDING" && run["run_status"] != "LAUNCHING" {
+			return
+		}
+	}
+	assert.Fail(fmt.Sprintf("Run %s is still %s", uniqID, run["run_status"]))
+	return
+}
+
+// createWebhookProject create a project with the webhook secret provided
+func createWebhookProject(t *testing.T, secret string) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	TestTeamsCreate(t)
+	result, err := teams.GetTeamIDForUnitTesting()
+	if err != nil {
+		log.Err(err).Msgf("Fail to retrieve team id")
+		t.Fail()
+		return
+	}
+
+	payload := fmt.Sprintf("name=%s", fake.CharactersN(10))
+	payload += fmt.Sprintf("&teamId=%s", result["team_id"])
+	payload += "&repository=https://github.com/cypress-io/cypress-example-kitchensink.git"
+	payload += "&branch=master"
+	payload += fmt.Sprintf("&specs=%s", tools.RandomValueFromSlice(specs))
+	payload += "&maxPods=10"
+	payload += "&browser=chrome"
+	payload += fmt.Sprintf("&webhookSecret=%s", secret)
+
+	router := SetupRouter()
+	w, _ := performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/projects", payload)
+	assert.Equal(201, w.Code)
+}
+
+func TestHooksGitHub(t *testing.T) {
+	assert := assert.New(t)
+
+	secret := fake.CharactersN(20)
+	createWebhookProject(t, secret)
+
+	router := SetupRouter()
+
+	push := `{"ref":"refs/heads/master","after":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","deleted":false,"repository":{"clone_url":"https://github.com/cypress-io/cypress-example-kitchensink.git","html_url":"https://github.com/cypress-io/cypress-example-kitchensink","ssh_url":"git@github.com:cypress-io/cypress-example-kitchensink.git"}}`
+	tag := `{"ref":"refs/tags/v1.0.0","after":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","deleted":false,"repository":{"clone_url":"https://github.com/cypress-io/cypress-example-kitchensink.git"}}`
+	unknown := `{"ref":"refs/heads/master","after":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","deleted":false,"repository":{"clone_url":"https://github.com/Lord-Y/unknown.git"}}`
+	branch := `{"ref":"refs/heads/feature","after":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","deleted":false,"repository":{"clone_url":"https://github.com/cypress-io/cypress-example-kitchensink.git"}}`
+	closed := `{"action":"closed","pull_request":{"head":{"ref":"feature","sha":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","repo":{"clone_url":"https://github.com/cypress-io/cypress-example-kitchensink.git"}}},"repository":{"clone_url":"https://github.com/cypress-io/cypress-example-kitchensink.git"}}`
+
+	tests := []struct {
+		event      string
+		payload    string
+		signature  string
+		statusCode int
+	}{
+		{
+			event:      "ping",
+			payload:    `{"zen":"Keep it logically awesome."}`,
+			statusCode: 200,
+		},
+		{
+			event:      "push",
+			payload:    tag,
+			signature:  hmacSHA256(secret, tag),
+			statusCode: 200,
+		},
+		{
+			event:      "push",
+			payload:    unknown,
+			signature:  hmacSHA256(secret, unknown),
+			statusCode: 200,
+		},
+		{
+			event:      "push",
+			payload:    branch,
+			signature:  hmacSHA256(secret, branch),
+			statusCode: 200,
+		},
+		{
+			event:      "pull_request",
+			payload:    closed,
+			signature:  hmacSHA256(secret, closed),
+			statusCode: 200,
+		},
+		{
+			event:      "push",
+			payload:    push,
+			signature:  hmacSHA256(fake.CharactersN(20), push),
+			statusCode: 401,
+		},
+		{
+			event:      "push",
+			payload:    push,
+			signature:  hmacSHA256(secret, push),
+			statusCode: 202,
+		},
+	}
+
+	for _, tc := range tests {
+		headers := make(map[string]string)
+		headers["Content-Type"] = "application/json"
+		headers["X-GitHub-Event"] = tc.event
+		if tc.signature != "" {
+			headers["X-Hub-Signature-256"] = fmt.Sprintf("sha256=%s", tc.signature)
+		}
+		w, _ := performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/hooks/launch/github", tc.payload)
+		assert.Equal(tc.statusCode, w.Code)
+	}
+}
+
+func TestHooksGitLab(t *testing.T) {
+	assert := assert.New(t)
+
+	secret := fake.CharactersN(20)
+	createWebhookProject(t, secret)
+
+	router := SetupRouter()
+	push := `{"object_kind":"push","ref":"refs/heads/master","after":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","project":{"git_http_url":"https://github.com/cypress-io/cypress-example-kitchensink.git"}}`
+	deleted := `{"object_kind":"push","ref":"refs/heads/master","after":"0000000000000000000000000000000000000000","project":{"git_http_url":"https://github.com/cypress-io/cypress-example-kitchensink.git"}}`
+	updated := `{"object_kind":"merge_request","project":{"git_http_url":"https://github.com/cypress-io/cypress-example-kitchensink.git"},"object_attributes":{"action":"update","source_branch":"master","target_branch":"master","source_project_id":1,"target_project_id":1,"last_commit":{"id":"6113728f27ae82c7b1a177c8d03f9e96e0adf246"}}}`
+	opened := `{"object_kind":"merge_request","project":{"git_http_url":"https://github.com/cypress-io/cypress-example-kitchensink.git"},"object_attributes":{"action":"open","source_branch":"master","target_branch":"master","source_project_id":1,"target_project_id":1,"last_commit":{"id":"6113728f27ae82c7b1a177c8d03f9e96e0adf246"}}}`
+
+	tests := []struct {
+		event      string
+		payload    string
+		token      string
+		statusCode int
+	}{
+		{
+			event:      "Tag Push Hook",
+			payload:    push,
+			token:      secret,
+			statusCode: 200,
+		},
+		{
+			event:      "Push Hook",
+			payload:    deleted,
+			token:      secret,
+			statusCode: 200,
+		},
+		{
+			event:      "Merge Request Hook",
+			payload:    updated,
+			token:      secret,
+			statusCode: 200,
+		},
+		{
+			event:      "Push Hook",
+			payload:    push,
+			token:      fake.CharactersN(20),
+			statusCode: 401,
+		},
+		{
+			event:      "Push Hook",
+			payload:    push,
+			token:      secret,
+			statusCode: 202,
+		},
+		{
+			event:      "Merge Request Hook",
+			payload:    opened,
+			token:      secret,
+			statusCode: 202,
+		},
+	}
+
+	for _, tc := range tests {
+		headers := make(map[string]string)
+		headers["Content-Type"] = "application/json"
+		headers["X-Gitlab-Event"] = tc.event
+		headers["X-Gitlab-Token"] = tc.token
+		w, _ := performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/hooks/launch/gitlab", tc.payload)
+		assert.Equal(tc.statusCode, w.Code)
+	}
+}
+
+func TestHooksGitea(t *testing.T) {
+	assert := assert.New(t)
+
+	secret := fake.CharactersN(20)
+	createWebhookProject(t, secret)
+
+	router := SetupRouter()
+	push := `{"ref":"refs/heads/master","after":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","repository":{"clone_url":"https://github.com/cypress-io/cypress-example-kitchensink.git"}}`
+	fork := `{"action":"opened","pull_request":{"head":{"ref":"master","sha":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","repo_id":2},"base":{"ref":"master","repo_id":1}},"repository":{"clone_url":"https://github.com/cypress-io/cypress-example-kitchensink.git"}}`
+	synchronized := `{"action":"synchronized","pull_request":{"head":{"ref":"master","sha":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","repo_id":1},"base":{"ref":"master","repo_id":1}},"repository":{"clone_url":"https://github.com/cypress-io/cypress-example-kitchensink.git"}}`
+
+	tests := []struct {
+		event      string
+		payload    string
+		signature  string
+		statusCode int
+	}{
+		{
+			event:      "create",
+			payload:    push,
+			signature:  hmacSHA256(secret, push),
+			statusCode: 200,
+		},
+		{
+			event:      "pull_request",
+			payload:    fork,
+			signature:  hmacSHA256(secret, fork),
+			statusCode: 200,
+		},
+		{
+			event:      "push",
+			payload:    push,
+			signature:  hmacSHA256(fake.CharactersN(20), push),
+			statusCode: 401,
+		},
+		{
+			event:      "push",
+			payload:    push,
+			signature:  hmacSHA256(secret, push),
+			statusCode: 202,
+		},
+		{
+			event:      "pull_request",
+			payload:    synchronized,
+			signature:  hmacSHA256(secret, synchronized),
+			statusCode: 202,
+		},
+	}
+
+	for _, tc := range tests {
+		headers := make(map[string]string)
+		headers["Content-Type"] = "application/json"
+		headers["X-Gitea-Event"] = tc.event
+		headers["X-Gitea-Signature"] = tc.signature
+		w, _ := performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/hooks/launch/gitea", tc.payload)
+		assert.Equal(tc.statusCode, w.Code)
+	}
+}
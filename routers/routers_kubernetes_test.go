@@ -0,0 +1,366 @@
+package routers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Lord-Y/cypress-parallel-api/commons"
+	"github.com/Lord-Y/cypress-parallel-api/hooks"
+	"github.com/Lord-Y/cypress-parallel-api/kubernetes"
+	"github.com/Lord-Y/cypress-parallel-api/kubernetes/fakeclient"
+	"github.com/Lord-Y/cypress-parallel-api/teams"
+	"github.com/icrowley/fake"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+// createFakeClientProject create a project running all advanced examples specs
+// with the max pods and kubernetes backend provided and return its name
+func createFakeClientProject(t *testing.T, maxPods int, kubernetesBackend string) (name string) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	TestTeamsCreate(t)
+	result, err := teams.GetTeamIDForUnitTesting()
+	if err != nil {
+		log.Err(err).Msgf("Fail to retrieve team id")
+		t.Fail()
+		return
+	}
+
+	name = fake.CharactersN(10)
+	payload := fmt.Sprintf("name=%s", name)
+	payload += fmt.Sprintf("&teamId=%s", result["team_id"])
+	payload += "&repository=https://github.com/cypress-io/cypress-example-kitchensink.git"
+	payload += "&branch=master"
+	payload += "&specs=cypress/integration/2-advanced-examples"
+	payload += fmt.Sprintf("&maxPods=%d", maxPods)
+	payload += "&browser=chrome"
+	payload += fmt.Sprintf("&kubernetes_backend=%s", kubernetesBackend)
+
+	router := SetupRouter()
+	w, _ := performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/projects", payload)
+	assert.Equal(201, w.Code)
+	return
+}
+
+// commandArg return the value of the flag provided in the container command
+func commandArg(command []string, flag string) string {
+	for k, v := range command {
+		if v == flag && k+1 < len(command) {
+			return command[k+1]
+		}
+	}
+	return ""
+}
+
+// podsOfRun return pods of the uniq id provided created in the fake client
+// or all of them when uniq id is empty
+func podsOfRun(t *testing.T, client *k8sfake.Clientset, uniqID string) (pods []v1.Pod) {
+	result, err := client.CoreV1().Pods(commons.GetKubernetesJobsNamespace()).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pod := range result.Items {
+		if uniqID == "" || commandArg(pod.Spec.Containers[0].Command, "--uid") == uniqID {
+			pods = append(pods, pod)
+		}
+	}
+	return
+}
+
+// reportResults report back results of all specs of the container command provided
+// to the router provided like cypress-parallel-cli does
+func reportResults(t *testing.T, router http.Handler, command []string) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	for _, spec := range strings.Split(commandArg(command, "--specs"), ",") {
+		payload := `result={}`
+		payload += "&executionStatus=DONE"
+		payload += fmt.Sprintf("&branch=%s", commandArg(command, "--branch"))
+		payload += fmt.Sprintf("&spec=%s", spec)
+		payload += fmt.Sprintf("&uniqId=%s", commandArg(command, "--uid"))
+
+		w, _ := performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/executions/update", payload)
+		assert.Equal(200, w.Code)
+	}
+}
+
+func TestKubernetesFakeClientLaunch(t *testing.T) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	client := fakeclient.New()
+	router := SetupRouterWithKubernetesClient(client)
+
+	name := createFakeClientProject(t, 2, "pod")
+
+	payload := fmt.Sprintf("project_name=%s", name)
+	uniqID, run := launchPlain(t, router, payload)
+	assert.Equal("LAUNCHED", run["run_status"])
+
+	_, err := client.CoreV1().Namespaces().Get(context.TODO(), commons.GetKubernetesJobsNamespace(), metav1.GetOptions{})
+	assert.NoError(err)
+	_, err = client.CoreV1().ServiceAccounts(commons.GetKubernetesJobsNamespace()).Get(context.TODO(), commons.GetKubernetesJobsNamespace(), metav1.GetOptions{})
+	assert.NoError(err)
+
+	pods := podsOfRun(t, client, "")
+	if !assert.Len(pods, 2) {
+		return
+	}
+	pod := pods[0]
+	command := pod.Spec.Containers[0].Command
+	assert.Equal(uniqID, commandArg(command, "--uid"))
+	assert.Equal("cypress-parallel-jobs", pod.Labels["app"])
+	assert.Equal("chrome", commandArg(command, "--browser"))
+	assert.Len(strings.Split(commandArg(command, "--specs"), ","), commons.GetMaxSpecs())
+	assert.Equal(run["commit_sha"], commandArg(command, "--commit"))
+	assert.Len(podsOfRun(t, client, uniqID), 2)
+
+	// all specs of the pod reported back, pod must be deleted
+	reportResults(t, router, command)
+	assert.Len(podsOfRun(t, client, uniqID), 1)
+	_, err = client.CoreV1().Pods(commons.GetKubernetesJobsNamespace()).Get(context.TODO(), pod.Name, metav1.GetOptions{})
+	assert.Error(err)
+
+	// a pod is available so the next queued specs must be started
+	hooks.Queued(client)
+	queued := podsOfRun(t, client, uniqID)
+	assert.Len(queued, 2)
+	for _, p := range queued {
+		assert.NotEqual(commandArg(command, "--specs"), commandArg(p.Spec.Containers[0].Command, "--specs"))
+		assert.Equal(run["commit_sha"], commandArg(p.Spec.Containers[0].Command, "--commit"))
+	}
+}
+
+func TestKubernetesFakeClientLaunch_maxPods(t *testing.T) {
+	assert := assert.New(t)
+
+	client := fakeclient.New()
+	router := SetupRouterWithKubernetesClient(client)
+
+	name := createFakeClientProject(t, 3, "pod")
+
+	// project max pods are used when the launch does not provide any
+	uniqID, run := launchPlain(t, router, fmt.Sprintf("project_name=%s", name))
+	assert.Equal("LAUNCHED", run["run_status"])
+	assert.Equal("3", run["max_pods"])
+	assert.Len(podsOfRun(t, client, uniqID), 3)
+
+	uniqID, run = launchPlain(t, router, fmt.Sprintf("project_name=%s&maxPods=1", name))
+	assert.Equal("LAUNCHED", run["run_status"])
+	assert.Equal("1", run["max_pods"])
+	pods := podsOfRun(t, client, uniqID)
+	if !assert.Len(pods, 1) {
+		return
+	}
+
+	// queued specs keep the max pods of the run
+	reportResults(t, router, pods[0].Spec.Containers[0].Command)
+	hooks.Queued(client)
+	assert.Len(podsOfRun(t, client, uniqID), 1)
+}
+
+func TestKubernetesFakeClientLaunch_job(t *testing.T) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	client := fakeclient.New()
+	router := SetupRouterWithKubernetesClient(client)
+
+	name := createFakeClientProject(t, 1, "job")
+
+	payload := fmt.Sprintf("project_name=%s", name)
+	_, run := launchPlain(t, router, payload)
+	assert.Equal("LAUNCHED", run["run_status"])
+
+	jobs, err := client.BatchV1().Jobs(commons.GetKubernetesJobsNamespace()).List(context.TODO(), metav1.ListOptions{})
+	assert.NoError(err)
+	if !assert.Len(jobs.Items, 1) {
+		return
+	}
+	job := jobs.Items[0]
+	assert.Equal(commons.GetKubernetesJobsBackoffLimit(), *job.Spec.BackoffLimit)
+	assert.NotZero(*job.Spec.ActiveDeadlineSeconds)
+
+	// all specs of the job reported back, job must be deleted
+	reportResults(t, router, job.Spec.Template.Spec.Containers[0].Command)
+	_, err = client.BatchV1().Jobs(commons.GetKubernetesJobsNamespace()).Get(context.TODO(), job.Name, metav1.GetOptions{})
+	assert.Error(err)
+}
+
+func TestKubernetesFakeClientCancel(t *testing.T) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	client := fakeclient.New()
+	router := SetupRouterWithKubernetesClient(client)
+
+	name := createFakeClientProject(t, 1, "pod")
+
+	payload := fmt.Sprintf("project_name=%s", name)
+	uniqID, run := launchPlain(t, router, payload)
+	assert.Equal("LAUNCHED", run["run_status"])
+
+	pods := podsOfRun(t, client, uniqID)
+	if !assert.Len(pods, 1) {
+		return
+	}
+
+	w, _ := performRequest(router, headers, "POST", fmt.Sprintf("/api/v1/cypress-parallel-api/executions/cancel/%s", uniqID), "")
+	assert.Equal(200, w.Code)
+	assert.Empty(podsOfRun(t, client, uniqID))
+
+	// cancelled runs must not be dequeued
+	hooks.Queued(client)
+	assert.Empty(podsOfRun(t, client, uniqID))
+
+	// cancelled runs are over so waiting for them must not block
+	w, _ = performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/runs/%s/wait?timeout=600", uniqID), "")
+	assert.Equal(200, w.Code)
+	assert.Contains(w.Body.String(), `"done":true`)
+	assert.Contains(w.Body.String(), `"exitCode":2`)
+}
+
+func TestKubernetesFakeClientLaunch_credentials(t *testing.T) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	client := fakeclient.New()
+	router := SetupRouterWithKubernetesClient(client)
+
+	name := createFakeClientProject(t, 1, "pod")
+
+	payload := fmt.Sprintf("project_name=%s", name)
+	_, run := launchPlain(t, router, payload)
+	assert.Equal("LAUNCHED", run["run_status"])
+
+	pods := podsOfRun(t, client, "")
+	if !assert.Len(pods, 1) {
+		return
+	}
+	command := pods[0].Spec.Containers[0].Command
+	uniqID := commandArg(command, "--uid")
+	assert.Empty(commandArg(command, "--password"))
+
+	// credentials are set after the launch so the repository can still be cloned
+	// and will be used by the queued specs
+	w, _ := performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/projects/search?q=%s", name), "")
+	var project []map[string]interface{}
+	if !assert.NoError(json.Unmarshal(w.Body.Bytes(), &project)) || !assert.Len(project, 1) {
+		return
+	}
+	projectID := fmt.Sprintf("%v", project[0]["project_id"])
+	password := fake.CharactersN(20)
+	payload = fmt.Sprintf("projectId=%s", projectID)
+	payload += fmt.Sprintf("&teamId=%v", project[0]["team_id"])
+	payload += fmt.Sprintf("&name=%s", name)
+	payload += "&repository=https://github.com/cypress-io/cypress-example-kitchensink.git"
+	payload += "&branch=master"
+	payload += "&specs=cypress/integration/2-advanced-examples"
+	payload += "&maxPods=1"
+	payload += "&username=user"
+	payload += fmt.Sprintf("&password=%s", password)
+	w, _ = performRequest(router, headers, "PUT", "/api/v1/cypress-parallel-api/projects", payload)
+	assert.Equal(200, w.Code)
+
+	reportResults(t, router, command)
+	hooks.Queued(client)
+	pods = podsOfRun(t, client, uniqID)
+	if !assert.Len(pods, 1) {
+		return
+	}
+	command = pods[0].Spec.Containers[0].Command
+	assert.Equal("$(GIT_PASSWORD)", commandArg(command, "--password"))
+	assert.NotContains(strings.Join(command, " "), password)
+
+	secretName := kubernetes.ProjectSecretName(projectID)
+	secret, err := client.CoreV1().Secrets(commons.GetKubernetesJobsNamespace()).Get(context.TODO(), secretName, metav1.GetOptions{})
+	assert.NoError(err)
+	assert.Equal(password, secret.StringData["password"])
+
+	w, _ = performRequest(router, headers, "DELETE", fmt.Sprintf("/api/v1/cypress-parallel-api/projects/%s", projectID), "")
+	assert.Equal(200, w.Code)
+	_, err = client.CoreV1().Secrets(commons.GetKubernetesJobsNamespace()).Get(context.TODO(), secretName, metav1.GetOptions{})
+	assert.Error(err)
+}
+
+func TestKubernetesFakeClientLaunch_dryRun(t *testing.T) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	client := fakeclient.New()
+	router := SetupRouterWithKubernetesClient(client)
+
+	name := createFakeClientProject(t, 1, "job")
+
+	payload := fmt.Sprintf("project_name=%s", name)
+	payload += "&dryRun=true"
+	w, _ := performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/hooks/launch/plain", payload)
+	if !assert.Equal(200, w.Code) {
+		return
+	}
+
+	var plan struct {
+		Branch string
+		Commit string
+		Specs  []string
+		Shards []struct {
+			Shard    int
+			Specs    []string
+			Status   string
+			Manifest batchv1.Job
+		}
+	}
+	if !assert.NoError(json.Unmarshal(w.Body.Bytes(), &plan)) || !assert.True(len(plan.Shards) > 1) {
+		return
+	}
+	assert.Equal("master", plan.Branch)
+	assert.Len(plan.Commit, 40)
+	var specs []string
+	for k, shard := range plan.Shards {
+		assert.Equal(k, shard.Shard)
+		specs = append(specs, shard.Specs...)
+		command := shard.Manifest.Spec.Template.Spec.Containers[0].Command
+		assert.Equal(strings.Join(shard.Specs, ","), commandArg(command, "--specs"))
+		assert.Equal(plan.Commit, commandArg(command, "--commit"))
+	}
+	assert.ElementsMatch(plan.Specs, specs)
+	// project max pods is 1 so only the first shard is started right away
+	assert.Equal("NOT_STARTED", plan.Shards[0].Status)
+	assert.Equal("QUEUED", plan.Shards[1].Status)
+
+	// nothing must be created in kubernetes nor in db
+	assert.Empty(client.Actions())
+	uniqID := commandArg(plan.Shards[0].Manifest.Spec.Template.Spec.Containers[0].Command, "--uid")
+	w, _ = performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/executions/list/by/uniqid/%s", uniqID), "")
+	assert.Equal(404, w.Code)
+	w, _ = performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/runs/%s", uniqID), "")
+	assert.Equal(404, w.Code)
+
+	// an abbreviated commit is resolved to the full SHA pods will checkout
+	w, _ = performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/hooks/launch/plain", fmt.Sprintf("%s&commit=%s", payload, plan.Commit[0:7]))
+	if assert.Equal(200, w.Code) && assert.NoError(json.Unmarshal(w.Body.Bytes(), &plan)) {
+		assert.Len(plan.Commit, 40)
+		assert.Equal(plan.Commit, commandArg(plan.Shards[0].Manifest.Spec.Template.Spec.Containers[0].Command, "--commit"))
+	}
+
+	w, _ = performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/hooks/launch/plain", payload+"&commit=fake")
+	assert.Equal(400, w.Code)
+}
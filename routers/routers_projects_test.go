@@ -1,8 +1,14 @@
 package routers
 
 import (
+	crand "crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"math/rand"
+	"net/url"
 	"testing"
 	"time"
 
@@ -12,6 +18,8 @@ import (
 	"github.com/icrowley/fake"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 func TestProjectsCreate(t *testing.T) {
@@ -69,6 +77,240 @@ func TestProjectsCreate_fail(t *testing.T) {
 	assert.Equal(201, w.Code)
 }
 
+func TestProjectsCreate_fail_scheduling(t *testing.T) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	TestTeamsCreate(t)
+	result, err := teams.GetTeamIDForUnitTesting()
+	if err != nil {
+		log.Err(err).Msgf("Fail to retrieve team id")
+		t.Fail()
+		return
+	}
+
+	router := SetupRouter()
+	tests := []struct {
+		scheduling        string
+		schedulingEnabled string
+		statusCode        int
+	}{
+		{
+			scheduling:        "* * *",
+			schedulingEnabled: "false",
+			statusCode:        400,
+		},
+		{
+			scheduling:        "61 * * * *",
+			schedulingEnabled: "true",
+			statusCode:        400,
+		},
+		{
+			scheduling:        "",
+			schedulingEnabled: "true",
+			statusCode:        400,
+		},
+		{
+			scheduling:        "*/10 * * * *",
+			schedulingEnabled: "true",
+			statusCode:        201,
+		},
+	}
+
+	for _, tc := range tests {
+		payload := fmt.Sprintf("name=%s", fake.CharactersN(10))
+		payload += fmt.Sprintf("&teamId=%s", result["team_id"])
+		payload += "&repository=https://github.com/cypress-io/cypress-example-kitchensink.git"
+		payload += "&branch=master"
+		payload += fmt.Sprintf("&specs=%s", tools.RandomValueFromSlice(specs))
+		payload += fmt.Sprintf("&scheduling=%s", tc.scheduling)
+		payload += fmt.Sprintf("&schedulingEnabled=%s", tc.schedulingEnabled)
+		payload += "&maxPods=10"
+		payload += fmt.Sprintf("&cypress_docker_version=%s", tools.RandomValueFromSlice(cypressVersions))
+		payload += "&browser=chrome"
+
+		w, _ := performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/projects", payload)
+		assert.Equal(tc.statusCode, w.Code)
+	}
+}
+
+// projectPayload return the form of a project of the team provided
+// with the fields of extra added or overriding the default ones
+func projectPayload(teamID string, extra string) string {
+	values := url.Values{}
+	values.Set("name", fake.CharactersN(10))
+	values.Set("teamId", teamID)
+	values.Set("repository", "https://github.com/cypress-io/cypress-example-kitchensink.git")
+	values.Set("branch", "master")
+	values.Set("specs", tools.RandomValueFromSlice(specs))
+	values.Set("maxPods", "10")
+	values.Set("cypress_docker_version", tools.RandomValueFromSlice(cypressVersions))
+	values.Set("browser", "chrome")
+
+	overrides, _ := url.ParseQuery(extra)
+	for key, value := range overrides {
+		values[key] = value
+	}
+	return values.Encode()
+}
+
+// sshKeys return a private key and known hosts of github.com matching it
+func sshKeys(t *testing.T) (privateKey string, knownHosts string) {
+	key, err := rsa.GenerateKey(crand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	knownHosts = knownhosts.Line([]string{"github.com"}, pub)
+	return
+}
+
+func TestProjectsCreate_validation(t *testing.T) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	TestTeamsCreate(t)
+	result, err := teams.GetTeamIDForUnitTesting()
+	if err != nil {
+		log.Err(err).Msgf("Fail to retrieve team id")
+		t.Fail()
+		return
+	}
+	privateKey, knownHosts := sshKeys(t)
+	sshRepository := "repository=git@github.com:cypress-io/cypress-example-kitchensink.git"
+
+	router := SetupRouter()
+	tests := []struct {
+		extra      string
+		statusCode int
+	}{
+		{
+			extra:      "kubernetes_backend=fake",
+			statusCode: 400,
+		},
+		{
+			extra:      "kubernetes_backend=pod",
+			statusCode: 201,
+		},
+		{
+			extra:      "kubernetes_backend=job",
+			statusCode: 201,
+		},
+		{
+			extra:      "balancing=fake",
+			statusCode: 400,
+		},
+		{
+			extra:      "balancing=chunk",
+			statusCode: 201,
+		},
+		{
+			extra:      "balancing=duration",
+			statusCode: 201,
+		},
+		{
+			extra:      "cpu_request=fake",
+			statusCode: 400,
+		},
+		{
+			extra:      "memory_request=4Gi&memory_limit=2Gi",
+			statusCode: 400,
+		},
+		{
+			extra:      "cpu_request=500m&cpu_limit=1&memory_request=1Gi&memory_limit=2Gi",
+			statusCode: 201,
+		},
+		{
+			extra:      fmt.Sprintf("node_selector=%s", url.QueryEscape(`["pool"]`)),
+			statusCode: 400,
+		},
+		{
+			extra:      "affinity=fake",
+			statusCode: 400,
+		},
+		{
+			extra:      fmt.Sprintf("node_selector=%s&tolerations=%s", url.QueryEscape(`{"pool": "browsers"}`), url.QueryEscape(`[{"key": "browsers", "operator": "Exists", "effect": "NoSchedule"}]`)),
+			statusCode: 201,
+		},
+		{
+			extra:      "image_pull_secrets=Registry_Secret",
+			statusCode: 400,
+		},
+		{
+			extra:      "image_repository=registry.example.com/cypress&image_pull_secrets=registry,mirror",
+			statusCode: 201,
+		},
+		{
+			extra:      "image=registry.example.com/cypress:latest",
+			statusCode: 201,
+		},
+		{
+			extra:      fmt.Sprintf("%s&ssh_private_key=fake", sshRepository),
+			statusCode: 400,
+		},
+		{
+			extra:      fmt.Sprintf("%s&ssh_private_key=%s&ssh_known_hosts=fake", sshRepository, url.QueryEscape(privateKey)),
+			statusCode: 400,
+		},
+		{
+			extra:      fmt.Sprintf("%s&ssh_private_key=%s&ssh_known_hosts=%s", sshRepository, url.QueryEscape(privateKey), url.QueryEscape(knownHosts)),
+			statusCode: 201,
+		},
+		{
+			extra:      fmt.Sprintf("specs_include=%s", url.QueryEscape("../**/*.js")),
+			statusCode: 400,
+		},
+		{
+			extra:      fmt.Sprintf("specs_exclude=%s", url.QueryEscape("cypress/{support")),
+			statusCode: 400,
+		},
+		{
+			extra:      fmt.Sprintf("specs_include=%s&specs_exclude=%s", url.QueryEscape("**/*.cy.{js,ts},**/*.spec.js"), url.QueryEscape("**/support/**")),
+			statusCode: 201,
+		},
+	}
+
+	for _, tc := range tests {
+		w, _ := performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/projects", projectPayload(result["team_id"], tc.extra))
+		assert.Equal(tc.statusCode, w.Code, tc.extra)
+	}
+}
+
+func TestProjectsCreate_ssh(t *testing.T) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	TestTeamsCreate(t)
+	result, err := teams.GetTeamIDForUnitTesting()
+	if err != nil {
+		log.Err(err).Msgf("Fail to retrieve team id")
+		t.Fail()
+		return
+	}
+	privateKey, knownHosts := sshKeys(t)
+
+	router := SetupRouter()
+	payload := projectPayload(result["team_id"], fmt.Sprintf("repository=git@github.com:cypress-io/cypress-example-kitchensink.git&ssh_private_key=%s&ssh_known_hosts=%s", url.QueryEscape(privateKey), url.QueryEscape(knownHosts)))
+	w, _ := performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/projects", payload)
+	if !assert.Equal(201, w.Code) {
+		return
+	}
+	var created map[string]int
+	if !assert.NoError(json.Unmarshal(w.Body.Bytes(), &created)) {
+		return
+	}
+	w, _ = performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/projects/%d", created["projectId"]), "")
+	assert.NotContains(w.Body.String(), "PRIVATE KEY")
+	assert.Contains(w.Body.String(), `"ssh_private_key_set":"true"`)
+}
+
 func TestProjectsRead(t *testing.T) {
 	assert := assert.New(t)
 	headers := make(map[string]string)
@@ -85,6 +327,59 @@ func TestProjectsRead(t *testing.T) {
 	assert.Contains(w.Body.String(), "name")
 }
 
+func TestProjectsSpecs(t *testing.T) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	result, err := projects.GetProjectIDForUnitTesting()
+	if err != nil {
+		log.Err(err).Msgf("Fail to retrieve project and team id")
+		t.Fail()
+		return
+	}
+	router := SetupRouter()
+	w, _ := performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/projects/%s/specs", result["project_id"]), "")
+	assert.Equal(200, w.Code)
+	assert.Contains(w.Body.String(), "tree")
+
+	w, _ = performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/projects/%s/specs?specs=cypress/integration/2-advanced-examples", result["project_id"]), "")
+	assert.Equal(200, w.Code)
+	for _, spec := range specs {
+		assert.Contains(w.Body.String(), spec)
+	}
+
+	w, _ = performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/projects/%s/specs?specs=fake", result["project_id"]), "")
+	assert.Equal(400, w.Code)
+
+	w, _ = performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/projects/%s/specs?branch=fake", result["project_id"]), "")
+	assert.Equal(400, w.Code)
+
+	w, _ = performRequest(router, headers, "GET", "/api/v1/cypress-parallel-api/projects/0/specs", "")
+	assert.Equal(404, w.Code)
+}
+
+func TestProjectsBranches(t *testing.T) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	result, err := projects.GetProjectIDForUnitTesting()
+	if err != nil {
+		log.Err(err).Msgf("Fail to retrieve project and team id")
+		t.Fail()
+		return
+	}
+	router := SetupRouter()
+	w, _ := performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/projects/%s/branches", result["project_id"]), "")
+	assert.Equal(200, w.Code)
+	assert.Contains(w.Body.String(), `"default":"master"`)
+	assert.Contains(w.Body.String(), `"master"`)
+
+	w, _ = performRequest(router, headers, "GET", "/api/v1/cypress-parallel-api/projects/0/branches", "")
+	assert.Equal(404, w.Code)
+}
+
 func TestProjectsList(t *testing.T) {
 	assert := assert.New(t)
 	headers := make(map[string]string)
@@ -151,6 +446,75 @@ func TestProjectsUpdate(t *testing.T) {
 	assert.Equal(200, w.Code)
 }
 
+func TestProjectsUpdate_secrets(t *testing.T) {
+	assert := assert.New(t)
+	headers := make(map[string]string)
+	headers["Content-Type"] = "application/x-www-form-urlencoded"
+
+	TestTeamsCreate(t)
+	result, err := teams.GetTeamIDForUnitTesting()
+	if err != nil {
+		log.Err(err).Msgf("Fail to retrieve team id")
+		t.Fail()
+		return
+	}
+
+	name := fake.CharactersN(10)
+	password := fake.CharactersN(20)
+	payload := fmt.Sprintf("name=%s", name)
+	payload += fmt.Sprintf("&teamId=%s", result["team_id"])
+	payload += "&repository=https://github.com/cypress-io/cypress-example-kitchensink.git"
+	payload += "&branch=master"
+	payload += fmt.Sprintf("&specs=%s", tools.RandomValueFromSlice(specs))
+	payload += "&browser=chrome"
+	payload += "&username=user"
+	payload += fmt.Sprintf("&password=%s", password)
+	payload += fmt.Sprintf("&webhookSecret=%s", fake.CharactersN(20))
+
+	router := SetupRouter()
+	w, _ := performRequest(router, headers, "POST", "/api/v1/cypress-parallel-api/projects", payload)
+	assert.Equal(201, w.Code)
+	var created map[string]int
+	if !assert.NoError(json.Unmarshal(w.Body.Bytes(), &created)) {
+		return
+	}
+	projectID := created["projectId"]
+
+	w, _ = performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/projects/%d", projectID), "")
+	assert.Equal(200, w.Code)
+	assert.NotContains(w.Body.String(), password)
+	assert.NotContains(w.Body.String(), `"password":`)
+	assert.Contains(w.Body.String(), `"password_set":"true"`)
+	assert.Contains(w.Body.String(), `"webhook_secret_set":"true"`)
+
+	w, _ = performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/projects/search?q=%s", name), "")
+	assert.NotContains(w.Body.String(), password)
+	assert.Contains(w.Body.String(), `"password_set":"true"`)
+
+	update := fmt.Sprintf("name=%s", name)
+	update += fmt.Sprintf("&projectId=%d", projectID)
+	update += fmt.Sprintf("&teamId=%s", result["team_id"])
+	update += "&repository=https://github.com/cypress-io/cypress-example-kitchensink.git"
+	update += "&branch=master"
+	update += fmt.Sprintf("&specs=%s", tools.RandomValueFromSlice(specs))
+	update += "&browser=chrome"
+	update += "&username=user"
+
+	// secrets not provided are kept
+	w, _ = performRequest(router, headers, "PUT", "/api/v1/cypress-parallel-api/projects", update)
+	assert.Equal(200, w.Code)
+	w, _ = performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/projects/%d", projectID), "")
+	assert.Contains(w.Body.String(), `"password_set":"true"`)
+	assert.Contains(w.Body.String(), `"webhook_secret_set":"true"`)
+
+	// secrets provided empty are removed
+	w, _ = performRequest(router, headers, "PUT", "/api/v1/cypress-parallel-api/projects", update+"&password=")
+	assert.Equal(200, w.Code)
+	w, _ = performRequest(router, headers, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/projects/%d", projectID), "")
+	assert.Contains(w.Body.String(), `"password_set":"false"`)
+	assert.Contains(w.Body.String(), `"webhook_secret_set":"true"`)
+}
+
 func TestProjectsDelete(t *testing.T) {
 	assert := assert.New(t)
 	headers := make(map[string]string)
@@ -0,0 +1,134 @@
+package routers
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Lord-Y/cypress-parallel-api/projects"
+	"github.com/Lord-Y/cypress-parallel-api/tools"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunsRead(t *testing.T) {
+	assert := assert.New(t)
+
+	TestProjectsCreate(t)
+	result, err := projects.GetProjectIDForUnitTesting()
+	if err != nil {
+		log.Err(err).Msgf("Fail to retrieve project and team id")
+		t.Fail()
+		return
+	}
+
+	router := SetupRouter()
+	payload := fmt.Sprintf("project_name=%s", result["project_name"])
+	payload += fmt.Sprintf("&specs=%s", tools.RandomValueFromSlice(specs))
+	payload += "&triggered_by=unit-testing"
+	_, run := launchPlain(t, router, payload)
+	assert.Equal("LAUNCHED", run["run_status"])
+	assert.Equal("unit-testing", run["triggered_by"])
+	assert.Equal(result["project_name"], run["project_name"])
+	assert.Len(run["commit_sha"], 40)
+	assert.NotEmpty(run["started_at"])
+	assert.Contains([]string{"RUNNING", "FAILED", "CANCELLED", "DONE"}, run["status"])
+
+	w, _ := performRequest(router, nil, "GET", "/api/v1/cypress-parallel-api/runs/fake", "")
+	assert.Equal(404, w.Code)
+}
+
+func TestRunsList(t *testing.T) {
+	assert := assert.New(t)
+
+	TestProjectsCreate(t)
+	result, err := projects.GetProjectIDForUnitTesting()
+	if err != nil {
+		log.Err(err).Msgf("Fail to retrieve project and team id")
+		t.Fail()
+		return
+	}
+
+	router := SetupRouter()
+	payload := fmt.Sprintf("project_name=%s", result["project_name"])
+	payload += fmt.Sprintf("&specs=%s", tools.RandomValueFromSlice(specs))
+	uniqID, run := launchPlain(t, router, payload)
+	assert.Equal("api", run["triggered_by"])
+
+	w, _ := performRequest(router, nil, "GET", "/api/v1/cypress-parallel-api/runs/list", "")
+	assert.Equal(200, w.Code)
+
+	w, _ = performRequest(router, nil, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/runs/list?projectId=%s&status=%s", result["project_id"], run["status"]), "")
+	if !assert.Equal(200, w.Code) {
+		return
+	}
+	var runs []map[string]string
+	if !assert.NoError(json.Unmarshal(w.Body.Bytes(), &runs)) {
+		return
+	}
+	var found bool
+	for _, r := range runs {
+		assert.Equal(result["project_id"], r["project_id"])
+		assert.Equal(run["status"], r["status"])
+		assert.NotEmpty(r["total"])
+		if r["uniq_id"] == uniqID {
+			found = true
+		}
+	}
+	assert.True(found)
+}
+
+func TestRunsList_fail(t *testing.T) {
+	assert := assert.New(t)
+
+	router := SetupRouter()
+	w, _ := performRequest(router, nil, "GET", "/api/v1/cypress-parallel-api/runs/list?status=fake", "")
+	assert.Equal(400, w.Code)
+
+	w, _ = performRequest(router, nil, "GET", "/api/v1/cypress-parallel-api/runs/list?projectId=0&branch=fake", "")
+	assert.Equal(204, w.Code)
+}
+
+func TestRunsWait(t *testing.T) {
+	assert := assert.New(t)
+
+	TestProjectsCreate(t)
+	result, err := projects.GetProjectIDForUnitTesting()
+	if err != nil {
+		log.Err(err).Msgf("Fail to retrieve project and team id")
+		t.Fail()
+		return
+	}
+
+	router := SetupRouter()
+	payload := fmt.Sprintf("project_name=%s", result["project_name"])
+	payload += fmt.Sprintf("&specs=%s", tools.RandomValueFromSlice(specs))
+	uniqID, _ := launchPlain(t, router, payload)
+
+	// pods don't report back in unit testing so the run is still running once timeout is reached
+	w, _ := performRequest(router, nil, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/runs/%s/wait?timeout=1", uniqID), "")
+	if !assert.Equal(200, w.Code) {
+		return
+	}
+	var verdict struct {
+		Run      map[string]string
+		Done     bool
+		ExitCode int
+		Summary  map[string]int
+		Specs    []map[string]string
+	}
+	if !assert.NoError(json.Unmarshal(w.Body.Bytes(), &verdict)) {
+		return
+	}
+	assert.Equal(uniqID, verdict.Run["uniq_id"])
+	assert.False(verdict.Done)
+	assert.Equal(3, verdict.ExitCode)
+	assert.Len(verdict.Specs, verdict.Summary["total"])
+	assert.NotZero(verdict.Summary["total"])
+
+	w, _ = performRequest(router, nil, "GET", "/api/v1/cypress-parallel-api/runs/fake/wait?timeout=0", "")
+	assert.Equal(404, w.Code)
+
+	w, _ = performRequest(router, nil, "GET", fmt.Sprintf("/api/v1/cypress-parallel-api/runs/%s/wait?timeout=3600", uniqID), "")
+	assert.Equal(400, w.Code)
+}
@@ -1,6 +1,9 @@
 package routers
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -38,3 +41,10 @@ func performRequest(r http.Handler, headers map[string]string, method string, ur
 	r.ServeHTTP(w, req)
 	return w, nil
 }
+
+// hmacSHA256 return the hex encoded hmac sha256 of the payload like git forges do
+func hmacSHA256(secret string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,213 @@
+// Package runs will manage all runs requirements
+package runs
+
+import (
+	"database/sql"
+
+	"github.com/Lord-Y/cypress-parallel-api/commons"
+	_ "github.com/lib/pq"
+	"github.com/syyongx/php2go"
+)
+
+// runsQuery select runs with their project name, executions count and aggregate status.
+// Once LAUNCHED, a run is RUNNING until all its executions are over, then FAILED when one of them failed,
+// CANCELLED when one of them was cancelled and DONE otherwise. It is finished when its last execution finished
+const runsQuery = `SELECT r.run_id, r.uniq_id, r.project_id, p.project_name, r.run_status, r.reason, r.branch, r.specs, r.browser, r.config_file, r.cypress_docker_version, r.max_pods, r.parent_uniq_id, r.triggered_by, r.requested_commit, r.commit_sha, r.date, r.started_at,
+CASE WHEN r.run_status = 'LAUNCHED' THEN CASE WHEN COALESCE(e.pending, 0) = 0 THEN e.finished_at END ELSE r.finished_at END finished_at,
+COALESCE(e.executions, 0) executions,
+CASE
+	WHEN r.run_status <> 'LAUNCHED' THEN r.run_status
+	WHEN e.pending > 0 THEN 'RUNNING'
+	WHEN e.failed > 0 THEN 'FAILED'
+	WHEN e.cancelled > 0 THEN 'CANCELLED'
+	ELSE 'DONE'
+END status
+FROM runs r
+LEFT JOIN projects p ON r.project_id = p.project_id
+LEFT JOIN (
+	SELECT run_id, COUNT(*) executions,
+	COUNT(*) FILTER (WHERE execution_status IN ('NOT_STARTED', 'QUEUED', 'SCHEDULED', 'RUNNING')) pending,
+	COUNT(*) FILTER (WHERE execution_status = 'FAILED') failed,
+	COUNT(*) FILTER (WHERE execution_status = 'CANCELLED') cancelled,
+	MAX(finished_at) finished_at
+	FROM executions GROUP BY run_id
+) e ON r.run_id = e.run_id`
+
+// list will return runs matching filters with range limit settings
+func (p *listRuns) list() (z []map[string]interface{}, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("SELECT *, COUNT(*) OVER () total FROM (" + runsQuery + ") runs WHERE ($1 = 0 OR project_id = $1) AND ($2 = '' OR branch = $2) AND ($3 = '' OR status = $3) ORDER BY date DESC OFFSET $4 LIMIT $5")
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(
+		p.ProjectID,
+		php2go.Addslashes(p.Branch),
+		php2go.Addslashes(p.Status),
+		p.StartLimit,
+		p.EndLimit,
+	)
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	m := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		err = rows.Scan(scanArgs...)
+		if err != nil {
+			return
+		}
+		var value string
+		sub := make(map[string]interface{})
+		for i, col := range values {
+			if col == nil {
+				value = ""
+			} else {
+				value = php2go.Stripslashes(string(col))
+			}
+			sub[columns[i]] = value
+		}
+		m = append(m, sub)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return m, nil
+}
+
+// read will return the run of the uniq id provided
+func (p *readRuns) read() (z map[string]string, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare(runsQuery + " WHERE r.uniq_id = $1 LIMIT 1")
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(
+		php2go.Addslashes(p.UniqID),
+	)
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	m := make(map[string]string)
+	for rows.Next() {
+		err = rows.Scan(scanArgs...)
+		if err != nil {
+			return
+		}
+		var value string
+		for i, col := range values {
+			if col == nil {
+				value = ""
+			} else {
+				value = php2go.Stripslashes(string(col))
+			}
+			m[columns[i]] = value
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return z, err
+	}
+	return m, nil
+}
+
+// specs will return the executions of the run of the uniq id provided with their duration in seconds if over
+func (p *readRuns) specs() (z []map[string]interface{}, err error) {
+	db, err := sql.Open(
+		"postgres",
+		commons.BuildDSN(),
+	)
+	if err != nil {
+		return
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("SELECT e.spec, e.execution_status, e.shard, e.pod_name, e.job_name, e.started_at, e.finished_at, EXTRACT(EPOCH FROM e.finished_at - e.started_at) duration FROM executions e INNER JOIN runs r ON e.run_id = r.run_id WHERE r.uniq_id = $1 ORDER BY e.shard, e.execution_id")
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(
+		php2go.Addslashes(p.UniqID),
+	)
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	scanArgs := make([]interface{}, len(values))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	m := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		err = rows.Scan(scanArgs...)
+		if err != nil {
+			return
+		}
+		var value string
+		sub := make(map[string]interface{})
+		for i, col := range values {
+			if col == nil {
+				value = ""
+			} else {
+				value = php2go.Stripslashes(string(col))
+			}
+			sub[columns[i]] = value
+		}
+		m = append(m, sub)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return m, nil
+}
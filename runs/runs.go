@@ -0,0 +1,110 @@
+// Package runs will manage all runs requirements
+package runs
+
+import (
+	"net/http"
+
+	"github.com/Lord-Y/cypress-parallel-api/commons"
+	"github.com/Lord-Y/cypress-parallel-api/events"
+	"github.com/Lord-Y/cypress-parallel-api/tools"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// listRuns struct handle requirements to get runs
+type listRuns struct {
+	Page       int    `form:"page,default=1" json:"page"`
+	ProjectID  int    `form:"projectId" json:"projectId"`
+	Branch     string `form:"branch" json:"branch" binding:"max=100"`
+	Status     string `form:"status" json:"status" binding:"omitempty,oneof=PENDING LAUNCHING RUNNING FAILED CANCELLED DONE"`
+	RangeLimit int
+	StartLimit int
+	EndLimit   int
+}
+
+// readRuns struct handle requirements to get runs
+type readRuns struct {
+	UniqID string `form:"uniqId" json:"uniqId" binding:"required"`
+}
+
+// List permit to retrieve runs with pagination, filtered by project, branch and aggregate status if provided
+func List(c *gin.Context) {
+	var (
+		p listRuns
+	)
+	if err := c.ShouldBind(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p.StartLimit, p.EndLimit = tools.GetPagination(p.Page, 0, commons.GetRangeLimit(), commons.GetRangeLimit())
+
+	result, err := p.list()
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	if len(result) == 0 {
+		c.AbortWithStatus(204)
+	} else {
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// Read permit to get the run of the uniq id provided.
+// run_status is PENDING until a launch worker picks the run up, LAUNCHING while the repository
+// is cloned and pods are created, then LAUNCHED or FAILED with the reason why, or CANCELLED before being launched.
+// status is the aggregate status of the run and its executions
+func Read(c *gin.Context) {
+	var (
+		p readRuns
+	)
+	id := c.Params.ByName("uniqId")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uniqId is missing in uri"})
+		return
+	}
+
+	p.UniqID = id
+	result, err := p.read()
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+
+	if len(result) == 0 {
+		c.AbortWithStatus(404)
+	} else {
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// Events permit to follow changes of the run of the uniq id provided and of its executions as server-sent events
+func Events(c *gin.Context) {
+	var (
+		p readRuns
+	)
+	id := c.Params.ByName("uniqId")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uniqId is missing in uri"})
+		return
+	}
+
+	p.UniqID = id
+	result, err := p.read()
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	if len(result) == 0 {
+		c.AbortWithStatus(404)
+		return
+	}
+
+	events.Serve(c, func(e events.Event) bool {
+		return e.UniqID == p.UniqID
+	})
+}
@@ -0,0 +1,118 @@
+// Package runs will manage all runs requirements
+package runs
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// waitInterval is the time between two checks of the status of a run waited for
+const waitInterval = 2 * time.Second
+
+// Exit codes suggested to callers according to the status of the run they waited for
+const (
+	exitDone      = 0 // all specs passed
+	exitFailed    = 1 // at least one spec failed or the run could not be launched
+	exitCancelled = 2 // the run was cancelled
+	exitRunning   = 3 // the run was not over before the timeout
+)
+
+// waitRuns struct handle requirements to wait for runs
+type waitRuns struct {
+	Timeout int `form:"timeout,default=60" json:"timeout" binding:"min=0,max=600"` // seconds
+}
+
+// terminal return true when the aggregate status provided won't change anymore
+func terminal(status string) bool {
+	return status == "DONE" || status == "FAILED" || status == "CANCELLED"
+}
+
+// exitCode return the exit code suggested for the aggregate status provided
+func exitCode(status string) int {
+	switch status {
+	case "DONE":
+		return exitDone
+	case "FAILED":
+		return exitFailed
+	case "CANCELLED":
+		return exitCancelled
+	default:
+		return exitRunning
+	}
+}
+
+// summarize return the number of specs per execution status and their total
+func summarize(specs []map[string]interface{}) map[string]int {
+	z := map[string]int{"total": len(specs)}
+	for _, spec := range specs {
+		z[fmt.Sprintf("%s", spec["execution_status"])]++
+	}
+	return z
+}
+
+// Wait permit to block until the run of the uniq id provided is over or timeout, in seconds, is reached.
+// It returns the run, whether it is done, the exit code suggested to CI pipelines
+// and the status of each of its specs
+func Wait(c *gin.Context) {
+	var (
+		p   waitRuns
+		r   readRuns
+		run map[string]string
+		err error
+	)
+	if err := c.ShouldBind(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	r.UniqID = c.Params.ByName("uniqId")
+	if r.UniqID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "uniqId is missing in uri"})
+		return
+	}
+
+	timeout := time.NewTimer(time.Duration(p.Timeout) * time.Second)
+	defer timeout.Stop()
+	ticker := time.NewTicker(waitInterval)
+	defer ticker.Stop()
+	for waiting := true; waiting; {
+		run, err = r.read()
+		if err != nil {
+			log.Error().Err(err).Msg("Error occured while performing db query")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+			return
+		}
+		if len(run) == 0 {
+			c.AbortWithStatus(404)
+			return
+		}
+		if terminal(run["status"]) {
+			break
+		}
+		// the request context is also done when the api shuts down
+		select {
+		case <-ticker.C:
+		case <-timeout.C:
+			waiting = false
+		case <-c.Request.Context().Done():
+			waiting = false
+		}
+	}
+
+	specs, err := r.specs()
+	if err != nil {
+		log.Error().Err(err).Msg("Error occured while performing db query")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal Server Error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"run":      run,
+		"done":     terminal(run["status"]),
+		"exitCode": exitCode(run["status"]),
+		"summary":  summarize(specs),
+		"specs":    specs,
+	})
+}
@@ -0,0 +1,43 @@
+// Package runs will manage all runs requirements
+package runs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		status   string
+		terminal bool
+		exitCode int
+	}{
+		{"DONE", true, 0},
+		{"FAILED", true, 1},
+		{"CANCELLED", true, 2},
+		{"PENDING", false, 3},
+		{"LAUNCHING", false, 3},
+		{"RUNNING", false, 3},
+	}
+	for _, tc := range tests {
+		assert.Equal(tc.terminal, terminal(tc.status), tc.status)
+		assert.Equal(tc.exitCode, exitCode(tc.status), tc.status)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(map[string]int{"total": 0}, summarize(nil))
+	assert.Equal(
+		map[string]int{"total": 3, "DONE": 2, "FAILED": 1},
+		summarize([]map[string]interface{}{
+			{"spec": "a.cy.js", "execution_status": "DONE"},
+			{"spec": "b.cy.js", "execution_status": "FAILED"},
+			{"spec": "c.cy.js", "execution_status": "DONE"},
+		}),
+	)
+}
@@ -0,0 +1 @@
+ALTER TABLE projects DROP COLUMN IF EXISTS scheduling_last_run, DROP COLUMN IF EXISTS scheduling_next_run;
\ No newline at end of file
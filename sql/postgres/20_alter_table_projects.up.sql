@@ -0,0 +1 @@
+ALTER TABLE projects ADD scheduling_last_run timestamp, ADD scheduling_next_run timestamp;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE projects ADD webhook_secret VARCHAR(100) DEFAULT '';
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE executions DROP COLUMN IF EXISTS browser, DROP COLUMN IF EXISTS config_file, DROP COLUMN IF EXISTS cypress_docker_version, DROP COLUMN IF EXISTS parent_uniq_id;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE executions ADD browser VARCHAR(100), ADD config_file VARCHAR(100), ADD cypress_docker_version VARCHAR(20), ADD parent_uniq_id VARCHAR(10);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE executions DROP COLUMN IF EXISTS started_at;
\ No newline at end of file
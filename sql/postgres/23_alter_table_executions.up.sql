@@ -0,0 +1 @@
+ALTER TABLE executions ADD started_at TIMESTAMP;
\ No newline at end of file
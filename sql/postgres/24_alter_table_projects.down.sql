@@ -0,0 +1,2 @@
+ALTER TABLE projects DROP COLUMN IF EXISTS kubernetes_backend;
+ALTER TABLE executions DROP COLUMN IF EXISTS job_name, DROP COLUMN IF EXISTS job_status;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE projects ADD kubernetes_backend VARCHAR(3) DEFAULT 'pod';
+ALTER TABLE executions ADD job_name VARCHAR(64) DEFAULT '', ADD job_status VARCHAR(20);
\ No newline at end of file
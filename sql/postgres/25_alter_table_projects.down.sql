@@ -0,0 +1,2 @@
+ALTER TABLE projects DROP COLUMN IF EXISTS balancing;
+ALTER TABLE executions DROP COLUMN IF EXISTS finished_at, DROP COLUMN IF EXISTS shard;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE projects ADD balancing VARCHAR(8) DEFAULT 'chunk';
+ALTER TABLE executions ADD finished_at TIMESTAMP, ADD shard INT;
\ No newline at end of file
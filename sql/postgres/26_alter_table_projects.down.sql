@@ -0,0 +1 @@
+ALTER TABLE projects DROP COLUMN IF EXISTS cpu_request, DROP COLUMN IF EXISTS cpu_limit, DROP COLUMN IF EXISTS memory_request, DROP COLUMN IF EXISTS memory_limit;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE projects ADD cpu_request VARCHAR(20) DEFAULT '', ADD cpu_limit VARCHAR(20) DEFAULT '', ADD memory_request VARCHAR(20) DEFAULT '', ADD memory_limit VARCHAR(20) DEFAULT '';
\ No newline at end of file
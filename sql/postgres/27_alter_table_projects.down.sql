@@ -0,0 +1 @@
+ALTER TABLE projects DROP COLUMN IF EXISTS node_selector, DROP COLUMN IF EXISTS tolerations, DROP COLUMN IF EXISTS affinity;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE projects ADD node_selector TEXT DEFAULT '', ADD tolerations TEXT DEFAULT '', ADD affinity TEXT DEFAULT '';
\ No newline at end of file
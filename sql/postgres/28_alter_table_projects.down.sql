@@ -0,0 +1 @@
+ALTER TABLE projects DROP COLUMN IF EXISTS image_repository, DROP COLUMN IF EXISTS image, DROP COLUMN IF EXISTS image_pull_secrets;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE projects ADD image_repository VARCHAR(255) DEFAULT '', ADD image VARCHAR(255) DEFAULT '', ADD image_pull_secrets VARCHAR(1000) DEFAULT '';
\ No newline at end of file
@@ -0,0 +1,8 @@
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM projects WHERE password LIKE 'enc:v1:%' OR webhook_secret LIKE 'enc:v1:%') THEN
+    RAISE EXCEPTION 'Projects secrets are encrypted, run cypress-parallel-api -decrypt before migrating down';
+  END IF;
+END
+$$;
+ALTER TABLE projects ALTER COLUMN password TYPE VARCHAR(100), ALTER COLUMN webhook_secret TYPE VARCHAR(100);
\ No newline at end of file
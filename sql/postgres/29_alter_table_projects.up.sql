@@ -0,0 +1 @@
+ALTER TABLE projects ALTER COLUMN password TYPE TEXT, ALTER COLUMN webhook_secret TYPE TEXT;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE projects DROP COLUMN IF EXISTS ssh_private_key, DROP COLUMN IF EXISTS ssh_known_hosts;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE projects ADD ssh_private_key TEXT DEFAULT '', ADD ssh_known_hosts TEXT DEFAULT '';
\ No newline at end of file
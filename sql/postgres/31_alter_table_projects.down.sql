@@ -0,0 +1 @@
+ALTER TABLE projects DROP COLUMN IF EXISTS specs_include, DROP COLUMN IF EXISTS specs_exclude;
\ No newline at end of file
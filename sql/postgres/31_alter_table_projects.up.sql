@@ -0,0 +1 @@
+ALTER TABLE projects ADD specs_include TEXT DEFAULT '', ADD specs_exclude TEXT DEFAULT '';
\ No newline at end of file
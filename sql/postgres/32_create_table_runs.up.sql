@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS runs (
+  run_id SERIAL PRIMARY KEY,
+  uniq_id VARCHAR(10) NOT NULL UNIQUE,
+  project_id INT NOT NULL,
+  run_status VARCHAR(20) NOT NULL DEFAULT 'PENDING',
+  reason TEXT DEFAULT '',
+  branch VARCHAR(100),
+  specs TEXT,
+  browser VARCHAR(100),
+  config_file VARCHAR(100),
+  cypress_docker_version VARCHAR(20),
+  max_pods INT,
+  parent_uniq_id VARCHAR(10),
+  date timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE runs
+ADD CONSTRAINT fk_runs_projects
+FOREIGN KEY (project_id)
+REFERENCES projects(project_id)
+ON DELETE CASCADE
+ON UPDATE CASCADE;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE executions DROP CONSTRAINT IF EXISTS fk_executions_runs;
+ALTER TABLE executions DROP COLUMN IF EXISTS run_id;
+ALTER TABLE runs DROP COLUMN IF EXISTS triggered_by, DROP COLUMN IF EXISTS commit_sha, DROP COLUMN IF EXISTS started_at, DROP COLUMN IF EXISTS finished_at;
\ No newline at end of file
@@ -0,0 +1,18 @@
+ALTER TABLE runs ADD triggered_by VARCHAR(100) DEFAULT '', ADD commit_sha VARCHAR(40) DEFAULT '', ADD started_at TIMESTAMP, ADD finished_at TIMESTAMP;
+
+INSERT INTO runs(uniq_id, project_id, run_status, branch, browser, config_file, cypress_docker_version, parent_uniq_id, triggered_by, started_at, date)
+SELECT uniq_id, MIN(project_id), 'LAUNCHED', MIN(branch), MIN(browser), MIN(config_file), MIN(cypress_docker_version), MIN(parent_uniq_id), '', MIN(date), MIN(date)
+FROM executions
+WHERE uniq_id NOT IN (SELECT uniq_id FROM runs)
+GROUP BY uniq_id;
+
+ALTER TABLE executions ADD run_id INT;
+UPDATE executions e SET run_id = r.run_id FROM runs r WHERE e.uniq_id = r.uniq_id;
+ALTER TABLE executions ALTER COLUMN run_id SET NOT NULL;
+
+ALTER TABLE executions
+ADD CONSTRAINT fk_executions_runs
+FOREIGN KEY (run_id)
+REFERENCES runs(run_id)
+ON DELETE CASCADE
+ON UPDATE CASCADE;
\ No newline at end of file
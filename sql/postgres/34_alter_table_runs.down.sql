@@ -0,0 +1 @@
+ALTER TABLE runs DROP COLUMN IF EXISTS requested_commit;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE runs ADD COLUMN requested_commit VARCHAR(100) DEFAULT '';
\ No newline at end of file